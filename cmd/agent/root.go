@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	osexec "os/exec"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// AgentCmd manages the background agent that keeps tokens renewed
+var AgentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Manage the background token agent",
+	Long: "The agent keeps client secrets, refresh tokens and access tokens in memory and renews the" +
+		" access tokens before they expire. While it runs, 'client token', 'exec' and 'credential' get" +
+		" their tokens from it over a socket only your user can open. Logins that need you, like the" +
+		" browser flow, still happen in the command; the agent takes over the refresh token afterwards.",
+}
+
+var (
+	agentStartCmd = &cobra.Command{
+		Use:   "start",
+		Short: "Start the agent in the background",
+		Args:  cobra.NoArgs,
+		Run:   agentStartExecute,
+	}
+
+	agentStopCmd = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the agent",
+		Args:  cobra.NoArgs,
+		Run:   agentStopExecute,
+	}
+
+	agentStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the agent runs and the tokens it holds",
+		Args:  cobra.NoArgs,
+		Run:   agentStatusExecute,
+	}
+
+	// agentRunCmd is the agent itself, started by agent start
+	agentRunCmd = &cobra.Command{
+		Use:    "run",
+		Short:  "Run the agent in the foreground",
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		Run:    agentRunExecute,
+	}
+)
+
+func init() {
+	AgentCmd.AddCommand(agentStartCmd)
+	AgentCmd.AddCommand(agentStopCmd)
+	AgentCmd.AddCommand(agentStatusCmd)
+	AgentCmd.AddCommand(agentRunCmd)
+}
+
+func agentStartExecute(cmd *cobra.Command, args []string) {
+	if status, err := common.GetAgentStatus(); err == nil {
+		fmt.Printf("The agent is already running (pid %d)\n", status.Pid)
+		return
+	}
+
+	rootConfigDir, err := config.InitConfigDirWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not find the config dir - %v\n", err)
+		os.Exit(1)
+	}
+	logFile, err := os.OpenFile(path.Join(rootConfigDir, config.AgentLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not open the agent log - %v\n", err)
+		os.Exit(1)
+	}
+	defer logFile.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not find the spsauth0 executable - %v\n", err)
+		os.Exit(1)
+	}
+
+	// the agent inherits the environment, and with it the config dir
+	agent := osexec.Command(executable, "agent", "run")
+	agent.Stdout = logFile
+	agent.Stderr = logFile
+	if err := agent.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not start the agent - %v\n", err)
+		os.Exit(1)
+	}
+	pid := agent.Process.Pid
+	agent.Process.Release()
+
+	// wait for the socket so commands run right after this use the agent
+	for i := 0; i < 50; i++ {
+		if _, err := common.GetAgentStatus(); err == nil {
+			fmt.Printf("Agent started (pid %d)\n", pid)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	fmt.Fprintf(os.Stderr, "The agent did not come up, see %s\n", path.Join(rootConfigDir, config.AgentLogFile))
+	os.Exit(1)
+}
+
+func agentStopExecute(cmd *cobra.Command, args []string) {
+	status, err := common.StopAgent()
+	if common.IsAgentNotRunning(err) {
+		fmt.Println("The agent is not running")
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not stop the agent - %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Agent stopped (pid %d)\n", status.Pid)
+}
+
+func agentStatusExecute(cmd *cobra.Command, args []string) {
+	status, err := common.GetAgentStatus()
+	if common.IsAgentNotRunning(err) {
+		fmt.Println("The agent is not running")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not reach the agent - %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("The agent is running (pid %d) since %s\n", status.Pid, time.Unix(status.Started, 0).Format(time.RFC3339))
+	if len(status.Tokens) == 0 {
+		fmt.Println("It holds no tokens yet")
+		return
+	}
+	for _, token := range status.Tokens {
+		expiry := "never expires"
+		if token.ExpiresAt > 0 {
+			expiry = "expires " + time.Unix(token.ExpiresAt, 0).Format(time.RFC3339)
+		}
+		name := token.TenantName + "/" + token.ClientName
+		if token.Username != "" {
+			name += " as " + token.Username
+		}
+		fmt.Printf("  %s  %s  [%s]  %s\n", name, token.Audience, token.Scope, expiry)
+	}
+}
+
+func agentRunExecute(cmd *cobra.Command, args []string) {
+	if status, err := common.GetAgentStatus(); err == nil {
+		fmt.Fprintf(os.Stderr, "The agent is already running (pid %d)\n", status.Pid)
+		os.Exit(1)
+	}
+
+	socketPath, err := common.AgentSocketPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not find the config dir - %v\n", err)
+		os.Exit(1)
+	}
+
+	// nothing answers on a socket left behind by an agent that died
+	os.Remove(socketPath)
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not listen on %s - %v\n", socketPath, err)
+		os.Exit(1)
+	}
+	// the agent hands out tokens to anyone who can open the socket
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		l.Close()
+		fmt.Fprintf(os.Stderr, "Error: could not restrict access to %s - %v\n", socketPath, err)
+		os.Exit(1)
+	}
+
+	agent := common.NewAgent()
+
+	// keep running when the terminal that started the agent goes away
+	signal.Ignore(syscall.SIGHUP)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		agent.Stop()
+	}()
+
+	fmt.Fprintf(os.Stderr, "%s: agent listening on %s (pid %d)\n", time.Now().Format(time.RFC3339), socketPath, os.Getpid())
+	agent.Serve(l)
+	fmt.Fprintf(os.Stderr, "%s: agent stopped\n", time.Now().Format(time.RFC3339))
+}
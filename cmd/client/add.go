@@ -6,9 +6,8 @@ import (
 
 //"github.com/manifoldco/promptui"
 "os"
-//"strconv"
-
-//"strconv"
+"strconv"
+"strings"
 
 "github.com/bluce-clj/spsauth0/internal/config"
 "github.com/spf13/cobra"
@@ -62,12 +61,90 @@ func clientAddExecute(cmd *cobra.Command, args []string) {
 	}
 	// Add some type of Validation to clientId
 
-	clientSecret, err := common.PromptString("Client Secret", "", false)
+	_, clientType, err := common.PromptSelect("Client Type", config.GetSupportedClientTypes())
 	if err != nil {
 		fmt.Println(err.Error())
 	}
 
-	_, clientType, err := common.PromptSelect("Client Type", config.GetSupportedClientTypes())
+	// Confidential clients can authenticate with a private key instead of a secret
+	authMethod := config.ClientAuthSecret
+	if clientType == "Machine-to-Machine Application" || clientType == "Web Service Application" {
+		_, authMethod, err = common.PromptSelect("Client Authentication", config.GetSupportedClientAuthMethods())
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+	}
+
+	clientSecret, privateKeyPath, privateKeyKid := "", "", ""
+	if authMethod == config.ClientAuthPrivateKeyJWT {
+		privateKeyPath, err = common.PromptString("Private Key (PEM file)", "", false)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		privateKeyKid, err = common.PromptString("Private Key Id (kid)", "", false)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+	} else {
+		clientSecret, err = common.PromptString("Client Secret", "", false)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+	}
+
+	flow := ""
+	if clientType != "Machine-to-Machine Application" {
+		_, flow, err = common.PromptSelect("Token Flow", config.GetSupportedFlows())
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+	}
+
+	// The browser flow listens for the login callback on the redirect URI
+	redirectURI, redirectPorts := "", []int(nil)
+	if flow == config.FlowBrowser {
+		redirectURI, err = common.PromptString("Redirect URI", common.DefaultRedirectURI, false)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		redirectPorts = promptRedirectPorts()
+	}
+
+	// The password flow can log in a stored test user through a specific connection
+	realm, username, password := "", "", ""
+	if flow == config.FlowPassword {
+		realm, err = common.PromptOptionalString("Realm/Connection (empty for the tenant default)", "")
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		username, err = common.PromptOptionalString("Test User (empty to enter it on every login)", "")
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		if username != "" {
+			password, err = common.PromptPassword("Test User Password")
+			if err != nil {
+				fmt.Println(err.Error())
+			}
+		}
+	}
+
+	// Logins of user flows can default to an organization and connection
+	organization, connection := "", ""
+	if clientType != "Machine-to-Machine Application" {
+		organization, err = common.PromptOptionalString("Organization (empty for none)", "")
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		if flow == config.FlowBrowser {
+			connection, err = common.PromptOptionalString("Connection (empty for the login page's choice)", "")
+			if err != nil {
+				fmt.Println(err.Error())
+			}
+		}
+	}
+
+	tags, err := common.PromptOptionalString("Tags (space separated, empty for none)", "")
 	if err != nil {
 		fmt.Println(err.Error())
 	}
@@ -77,12 +154,34 @@ func clientAddExecute(cmd *cobra.Command, args []string) {
 		fmt.Println(err.Error())
 	}
 
+	audience := promptClientAudience(tenantConfig.GetTenantConfig(strings.ToLower(tenant)))
+
 	newClient := &config.Client{
 		ClientId:     clientId,
 		ClientSecret: clientSecret,
 		ClientName:   clientName,
 		ClientType:   clientType,
-		TenantName: tenant,
+		TenantName:   tenant,
+		Audience:     audience,
+		Flow:         flow,
+		Realm:        realm,
+		Username:     username,
+		Password:     password,
+
+		PrivateKeyPath: privateKeyPath,
+		PrivateKeyKid:  privateKeyKid,
+		RedirectURI:    redirectURI,
+		RedirectPorts:  redirectPorts,
+		Organization:   organization,
+		Connection:     connection,
+		Tags:           strings.Fields(tags),
+	}
+
+	// Keep the secrets in the secret store, the config only refers to them
+	newClient, err = config.StoreClientSecrets(newClient)
+	if err != nil {
+		fmt.Println("Failed to store the client's secrets: ", err)
+		os.Exit(1)
 	}
 
 	// Save Client to config
@@ -94,3 +193,54 @@ func clientAddExecute(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 }
+
+// promptRedirectPorts asks for the ports to fall back on when the redirect
+// URI's port is taken. Every one of them must be an allowed callback in DevCenter.
+func promptRedirectPorts() []int {
+	for {
+		input, err := common.PromptOptionalString("Redirect Ports to try in order (comma separated, empty for only the URI's port)", "")
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil
+		}
+		if strings.TrimSpace(input) == "" {
+			return nil
+		}
+
+		ports := make([]int, 0)
+		for _, field := range strings.Split(input, ",") {
+			port, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || port < 1 || port > 65535 {
+				ports = nil
+				break
+			}
+			ports = append(ports, port)
+		}
+		if ports != nil {
+			return ports
+		}
+		fmt.Printf("%s is not a comma separated list of ports\n", input)
+	}
+}
+
+// promptClientAudience asks which of the tenant's APIs the client requests
+// tokens for by default. Leaving it empty falls back to the tenant's default.
+func promptClientAudience(tenant *config.Tenant) string {
+	if tenant == nil || len(tenant.Tenant.APIs) == 0 {
+		return ""
+	}
+
+	names := []string{"Tenant Default"}
+	for _, api := range tenant.Tenant.APIs {
+		names = append(names, api.Name)
+	}
+	i, _, err := common.PromptSelect("Default Audience", names)
+	if err != nil {
+		fmt.Println(err.Error())
+		return ""
+	}
+	if i == 0 {
+		return ""
+	}
+	return tenant.Tenant.APIs[i-1].Audience
+}
@@ -38,6 +38,7 @@ func init() {
 	ClientCmd.AddCommand(clientAddCmd)
 	ClientCmd.AddCommand(clientListCmd)
 	ClientCmd.AddCommand(clientTokenCmd)
+	ClientCmd.AddCommand(clientUserinfoCmd)
 }
 
 // InitRootConfig initializes and loads the config for auth0 clients
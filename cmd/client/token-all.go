@@ -0,0 +1,110 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// bulkTokenReport is one line of the --all report in json output
+type bulkTokenReport struct {
+	Tenant       string `json:"tenant"`
+	Client       string `json:"client"`
+	Audience     string `json:"audience,omitempty"`
+	OK           bool   `json:"ok"`
+	LatencyMs    int64  `json:"latency_ms"`
+	GrantedScope string `json:"granted_scope,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// clientTokenAllExecute fetches a token for every machine to machine client
+// and reports how each went. It exits 1 when any of them failed, so it can
+// be used as a smoke test.
+func clientTokenAllExecute(cmd *cobra.Command) {
+	clientConfig, err := config.LoadClientConfigWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not load client config - %v\n", err)
+		os.Exit(1)
+	}
+
+	clients := clientConfig.GetClients(viper.GetString(config.KeyCmdClientTokenTenant), viper.GetString(config.KeyCmdClientTokenTag))
+	results := common.GetClientTokens(clients, common.TokenOptions{
+		Audience:    viper.GetString(config.KeyCmdClientTokenAudience),
+		Scope:       viper.GetString(config.KeyCmdClientTokenScope),
+		ExtraParams: getExtraParams(cmd),
+	}, viper.GetInt(config.KeyCmdClientTokenWorkers))
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "No machine to machine clients match, use 'spsauth0 client list' to list configured clients.")
+		os.Exit(1)
+	}
+
+	reports := make([]bulkTokenReport, 0, len(results))
+	failed := 0
+	for _, result := range results {
+		report := bulkTokenReport{
+			Tenant:       result.Client.TenantName,
+			Client:       result.Client.ClientName,
+			Audience:     result.Audience,
+			OK:           result.Err == nil,
+			LatencyMs:    result.Latency.Milliseconds(),
+			GrantedScope: result.GrantedScope,
+		}
+		if result.Err != nil {
+			report.Error = result.Err.Error()
+			failed++
+		}
+		reports = append(reports, report)
+	}
+
+	if viper.GetString(config.KeyCmdClientTokenOutput) == common.OutputJSON {
+		out, _ := json.MarshalIndent(reports, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		printBulkTokenReport(reports)
+		fmt.Printf("\n%d of %d clients got a token\n", len(reports)-failed, len(reports))
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func printBulkTokenReport(reports []bulkTokenReport) {
+	rows := make([][]string, 0, len(reports))
+	for _, report := range reports {
+		result := "ok"
+		if !report.OK {
+			result = "FAILED: " + report.Error
+		}
+		latency := ""
+		if report.LatencyMs > 0 || report.OK {
+			latency = fmt.Sprintf("%dms", report.LatencyMs)
+		}
+		rows = append(rows, []string{
+			report.Tenant,
+			report.Client,
+			report.Audience,
+			latency,
+			report.GrantedScope,
+			result,
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Tenant", "Client", "Audience", "Latency", "Granted Scopes", "Result"})
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetCenterSeparator(" ")
+	table.SetColumnSeparator(" ")
+	table.SetHeaderLine(false)
+	table.SetAutoWrapText(false)
+	table.AppendBulk(rows)
+	table.Render()
+}
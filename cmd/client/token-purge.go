@@ -0,0 +1,43 @@
+package client
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	clientTokenPurgeCmd = &cobra.Command{
+		Use:   "purge",
+		Short: "Remove cached tokens",
+		Long: "Remove the access tokens cached by 'spsauth0 client token'. Use --tenant, --client" +
+			" and --username to only remove the tokens of a single tenant, client or test user.",
+		Args: cobra.NoArgs,
+		Run:  clientTokenPurgeExecute,
+	}
+)
+
+func init() {
+	clientTokenPurgeCmd.Flags().String(config.FlagCmdClientTokenPurgeTenant, "", config.DescCmdClientTokenPurgeTenant)
+	viper.BindPFlag(config.KeyCmdClientTokenPurgeTenant, clientTokenPurgeCmd.Flags().Lookup(config.FlagCmdClientTokenPurgeTenant))
+	clientTokenPurgeCmd.Flags().String(config.FlagCmdClientTokenPurgeClient, "", config.DescCmdClientTokenPurgeClient)
+	viper.BindPFlag(config.KeyCmdClientTokenPurgeClient, clientTokenPurgeCmd.Flags().Lookup(config.FlagCmdClientTokenPurgeClient))
+	clientTokenPurgeCmd.Flags().String(config.FlagCmdClientTokenPurgeUsername, "", config.DescCmdClientTokenPurgeUsername)
+	viper.BindPFlag(config.KeyCmdClientTokenPurgeUsername, clientTokenPurgeCmd.Flags().Lookup(config.FlagCmdClientTokenPurgeUsername))
+}
+
+func clientTokenPurgeExecute(cmd *cobra.Command, args []string) {
+	purged := 0
+	err := config.UpdateTokenCacheWithViper(func(tokenCache *config.TokenCache) {
+		purged = tokenCache.Purge(viper.GetString(config.KeyCmdClientTokenPurgeTenant),
+			viper.GetString(config.KeyCmdClientTokenPurgeClient), viper.GetString(config.KeyCmdClientTokenPurgeUsername))
+	})
+	if err != nil {
+		fmt.Println("Failed to save token cache: ", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Purged %d cached token(s)\n", purged)
+}
@@ -5,41 +5,137 @@ import (
 	"github.com/bluce-clj/spsauth0/common"
 	"github.com/bluce-clj/spsauth0/internal/config"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"os"
-	"strings"
 )
 
 var (
 	clientTokenCmd = &cobra.Command{
-		Use:     "token",
-		Short:   "Get a token for a configured client",
-		Long: "If getting a token for a native client you must have a redirect of" +
-			" http://localhost:1000 configured via DevCenter for authtool to work.",
+		Use:   "token",
+		Short: "Get a token for a configured client",
+		Long: "If getting a token with the browser flow the redirect URI of the client (http://localhost:1000" +
+			" unless configured otherwise) must be an allowed callback in DevCenter for authtool to work.",
 		Aliases: []string{"tk"},
 		Args:    cobra.NoArgs,
 		Run:     clientTokenExecute,
 	}
 )
 
+func init() {
+	clientTokenCmd.Flags().Bool(config.FlagCmdClientTokenNoCache, false, config.DescCmdClientTokenNoCache)
+	viper.BindPFlag(config.KeyCmdClientTokenNoCache, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenNoCache))
+	clientTokenCmd.Flags().Bool(config.FlagCmdClientTokenRefresh, false, config.DescCmdClientTokenRefresh)
+	viper.BindPFlag(config.KeyCmdClientTokenRefresh, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenRefresh))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenFlow, "", config.DescCmdClientTokenFlow)
+	viper.BindPFlag(config.KeyCmdClientTokenFlow, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenFlow))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenUsername, "", config.DescCmdClientTokenUsername)
+	viper.BindPFlag(config.KeyCmdClientTokenUsername, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenUsername))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenPassword, "", config.DescCmdClientTokenPassword)
+	viper.BindPFlag(config.KeyCmdClientTokenPassword, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenPassword))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenRedirectURI, "", config.DescCmdClientTokenRedirectURI)
+	viper.BindPFlag(config.KeyCmdClientTokenRedirectURI, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenRedirectURI))
+	clientTokenCmd.Flags().IntSlice(config.FlagCmdClientTokenRedirectPorts, nil, config.DescCmdClientTokenRedirectPorts)
+	viper.BindPFlag(config.KeyCmdClientTokenRedirectPorts, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenRedirectPorts))
+	clientTokenCmd.Flags().Bool(config.FlagCmdClientTokenNoBrowser, false, config.DescCmdClientTokenNoBrowser)
+	viper.BindPFlag(config.KeyCmdClientTokenNoBrowser, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenNoBrowser))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenAudience, "", config.DescCmdClientTokenAudience)
+	viper.BindPFlag(config.KeyCmdClientTokenAudience, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenAudience))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenScope, "", config.DescCmdClientTokenScope)
+	viper.BindPFlag(config.KeyCmdClientTokenScope, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenScope))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenOrganization, "", config.DescCmdClientTokenOrganization)
+	viper.BindPFlag(config.KeyCmdClientTokenOrganization, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenOrganization))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenConnection, "", config.DescCmdClientTokenConnection)
+	viper.BindPFlag(config.KeyCmdClientTokenConnection, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenConnection))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenPrompt, "", config.DescCmdClientTokenPrompt)
+	viper.BindPFlag(config.KeyCmdClientTokenPrompt, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenPrompt))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenScreenHint, "", config.DescCmdClientTokenScreenHint)
+	viper.BindPFlag(config.KeyCmdClientTokenScreenHint, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenScreenHint))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenLoginHint, "", config.DescCmdClientTokenLoginHint)
+	viper.BindPFlag(config.KeyCmdClientTokenLoginHint, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenLoginHint))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenMaxAge, "", config.DescCmdClientTokenMaxAge)
+	viper.BindPFlag(config.KeyCmdClientTokenMaxAge, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenMaxAge))
+	clientTokenCmd.Flags().StringArray(config.FlagCmdClientTokenParam, nil, config.DescCmdClientTokenParam)
+	viper.BindPFlag(config.KeyCmdClientTokenParam, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenParam))
+	clientTokenCmd.Flags().StringP(config.FlagCmdClientTokenOutput, "o", common.OutputRaw, config.DescCmdClientTokenOutput)
+	viper.BindPFlag(config.KeyCmdClientTokenOutput, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenOutput))
+	clientTokenCmd.Flags().Bool(config.FlagCmdClientTokenCopy, false, config.DescCmdClientTokenCopy)
+	viper.BindPFlag(config.KeyCmdClientTokenCopy, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenCopy))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenSnippet, "", config.DescCmdClientTokenSnippet)
+	viper.BindPFlag(config.KeyCmdClientTokenSnippet, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenSnippet))
+	clientTokenCmd.Flags().Bool(config.FlagCmdClientTokenIncludeSecrets, false, config.DescCmdClientTokenIncludeSecrets)
+	viper.BindPFlag(config.KeyCmdClientTokenIncludeSecrets, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenIncludeSecrets))
+
+	clientTokenCmd.Flags().Bool(config.FlagCmdClientTokenAll, false, config.DescCmdClientTokenAll)
+	viper.BindPFlag(config.KeyCmdClientTokenAll, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenAll))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenTenant, "", config.DescCmdClientTokenTenant)
+	viper.BindPFlag(config.KeyCmdClientTokenTenant, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenTenant))
+	clientTokenCmd.Flags().String(config.FlagCmdClientTokenTag, "", config.DescCmdClientTokenTag)
+	viper.BindPFlag(config.KeyCmdClientTokenTag, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenTag))
+	clientTokenCmd.Flags().Int(config.FlagCmdClientTokenWorkers, config.DefaultClientTokenWorkers, config.DescCmdClientTokenWorkers)
+	viper.BindPFlag(config.KeyCmdClientTokenWorkers, clientTokenCmd.Flags().Lookup(config.FlagCmdClientTokenWorkers))
+
+	clientTokenCmd.AddCommand(clientTokenPurgeCmd)
+}
+
 func clientTokenExecute(cmd *cobra.Command, args []string) {
-	// Get configured clients
-	clientConfig, err := config.LoadClientConfigWithViper()
-	if err != nil {
-		fmt.Printf("Error: could not load tenant config - %v\n", err)
-		os.Exit(1)
+	if viper.GetBool(config.KeyCmdClientTokenAll) {
+		clientTokenAllExecute(cmd)
+		return
 	}
 
-	_, selectedClient, err := common.PromptSelect("Clients", config.GetClientListNames(*clientConfig.GetClientList("all")) )
-	if err != nil {
-		fmt.Printf(err.Error())
+	// check the format before a login is spent on a token that can not be printed
+	format := viper.GetString(config.KeyCmdClientTokenOutput)
+	if !common.IsSupportedOutputFormat(format) {
+		fmt.Fprintf(os.Stderr, "Unsupported output format %s, use one of %v\n", format, common.GetSupportedOutputFormats())
 		os.Exit(1)
 	}
-	// Check that a client does not already exist in the config with the same name
-	client := clientConfig.GetClientConfig(strings.ToLower(selectedClient))
-	if client == nil {
-		fmt.Printf("Error getting client configuration")
-		os.Exit(1)
+
+	client := common.SelectClient("")
+
+	opts := common.TokenOptions{
+		NoCache:  viper.GetBool(config.KeyCmdClientTokenNoCache),
+		Refresh:  viper.GetBool(config.KeyCmdClientTokenRefresh),
+		Flow:     viper.GetString(config.KeyCmdClientTokenFlow),
+		Username: viper.GetString(config.KeyCmdClientTokenUsername),
+		Password: viper.GetString(config.KeyCmdClientTokenPassword),
+
+		RedirectURI:   viper.GetString(config.KeyCmdClientTokenRedirectURI),
+		RedirectPorts: viper.GetIntSlice(config.KeyCmdClientTokenRedirectPorts),
+		NoBrowser:     viper.GetBool(config.KeyCmdClientTokenNoBrowser),
+		Audience:      viper.GetString(config.KeyCmdClientTokenAudience),
+		Scope:         viper.GetString(config.KeyCmdClientTokenScope),
+
+		Organization: viper.GetString(config.KeyCmdClientTokenOrganization),
+		Connection:   viper.GetString(config.KeyCmdClientTokenConnection),
+		Prompt:       viper.GetString(config.KeyCmdClientTokenPrompt),
+		ScreenHint:   viper.GetString(config.KeyCmdClientTokenScreenHint),
+		LoginHint:    viper.GetString(config.KeyCmdClientTokenLoginHint),
+		MaxAge:       viper.GetString(config.KeyCmdClientTokenMaxAge),
+		ExtraParams:  getExtraParams(cmd),
+	}
+
+	// print the request for the token instead of making it
+	if language := viper.GetString(config.KeyCmdClientTokenSnippet); language != "" {
+		fmt.Println(common.GetTokenSnippet(client, opts, language, viper.GetBool(config.KeyCmdClientTokenIncludeSecrets)))
+		return
 	}
 
-	fmt.Println(common.GetTokenHandler(client))
+	output := common.FormatToken(common.GetTokenWithAgent(client, opts), format)
+	if viper.GetBool(config.KeyCmdClientTokenCopy) {
+		err := common.CopyToClipboard(output)
+		if err == nil {
+			fmt.Fprintln(os.Stderr, "Copied to the clipboard")
+			return
+		}
+		// the token is still good, print it instead
+		fmt.Fprintf(os.Stderr, "Warning: could not copy to the clipboard - %v\n", err)
+	}
+	fmt.Println(output)
+}
+
+// getExtraParams returns the --param values. They are read from the flag
+// itself since viper reads an unset string array flag as "[]".
+func getExtraParams(cmd *cobra.Command) []string {
+	params, _ := cmd.Flags().GetStringArray(config.FlagCmdClientTokenParam)
+	return params
 }
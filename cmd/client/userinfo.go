@@ -0,0 +1,60 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	clientUserinfoCmd = &cobra.Command{
+		Use:   "userinfo",
+		Short: "Print the profile of the user a client logs in",
+		Long: "Log in with a configured client, or reuse its cached token, and print the profile the" +
+			" tenant's /userinfo endpoint returns along with the claims of the ID token.",
+		Args: cobra.NoArgs,
+		Run:  clientUserinfoExecute,
+	}
+)
+
+func init() {
+	clientUserinfoCmd.Flags().Bool(config.FlagCmdClientTokenNoCache, false, config.DescCmdClientTokenNoCache)
+	viper.BindPFlag(config.KeyCmdClientUserinfoNoCache, clientUserinfoCmd.Flags().Lookup(config.FlagCmdClientTokenNoCache))
+	clientUserinfoCmd.Flags().String(config.FlagCmdClientTokenFlow, "", config.DescCmdClientTokenFlow)
+	viper.BindPFlag(config.KeyCmdClientUserinfoFlow, clientUserinfoCmd.Flags().Lookup(config.FlagCmdClientTokenFlow))
+	clientUserinfoCmd.Flags().Bool(config.FlagCmdClientTokenNoBrowser, false, config.DescCmdClientTokenNoBrowser)
+	viper.BindPFlag(config.KeyCmdClientUserinfoNoBrowser, clientUserinfoCmd.Flags().Lookup(config.FlagCmdClientTokenNoBrowser))
+}
+
+func clientUserinfoExecute(cmd *cobra.Command, args []string) {
+	client := common.SelectClient("")
+	if client.ClientType == "Machine-to-Machine Application" {
+		fmt.Printf("%s is a Machine-to-Machine Application, its tokens do not belong to a user\n", client.ClientName)
+		os.Exit(1)
+	}
+
+	token := common.GetToken(client, common.TokenOptions{
+		NoCache:   viper.GetBool(config.KeyCmdClientUserinfoNoCache),
+		Flow:      viper.GetString(config.KeyCmdClientUserinfoFlow),
+		NoBrowser: viper.GetBool(config.KeyCmdClientUserinfoNoBrowser),
+	})
+
+	profile, _ := json.MarshalIndent(common.GetUserInfo(client, token.AccessToken), "", "  ")
+	fmt.Printf("Profile:\n%s\n", profile)
+
+	if token.IDToken == "" {
+		return
+	}
+	idToken, err := common.DecodeJWT(token.IDToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not decode the ID token - %v\n", err)
+		return
+	}
+	claims, _ := json.MarshalIndent(idToken.Claims, "", "  ")
+	fmt.Printf("\nID Token Claims:\n%s\n", claims)
+}
@@ -0,0 +1,109 @@
+package credential
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	credentialDockerCmd = &cobra.Command{
+		Use:   "docker <get|store|erase|list>",
+		Short: "Docker credential helper",
+		Long: "Speak the docker credential helper protocol. get returns the client id as the username" +
+			" and the token as the secret for the registries given with --registry, and no credentials" +
+			" for any other. There is nothing to store, so store and erase do nothing and list is empty." +
+			" A docker-credential-spsauth0 script running" +
+			" 'spsauth0 credential docker --registry <registry> \"$@\"' makes it usable as a credsStore or credHelper.",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"get", "store", "erase", "list"},
+		Run:       credentialDockerExecute,
+	}
+)
+
+func init() {
+	credentialDockerCmd.Flags().StringArray(config.FlagCmdCredentialDockerRegistry, nil, config.DescCmdCredentialDockerRegistry)
+	viper.BindPFlag(config.KeyCmdCredentialDockerRegistry, credentialDockerCmd.Flags().Lookup(config.FlagCmdCredentialDockerRegistry))
+}
+
+// errDockerCredentialsNotFound is the answer docker takes as there being no
+// credentials for a registry
+const errDockerCredentialsNotFound = "credentials not found in native keychain"
+
+type dockerCredential struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+func credentialDockerExecute(cmd *cobra.Command, args []string) {
+	switch args[0] {
+	case "get":
+		// docker writes the registry's server URL on stdin
+		serverURL, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && serverURL == "" {
+			fmt.Fprintf(os.Stderr, "Error: could not read the server URL - %v\n", err)
+			os.Exit(1)
+		}
+
+		serverURL = strings.TrimSpace(serverURL)
+
+		// the token is only for the registries it was meant for, docker asks
+		// the credsStore about every registry it talks to
+		registries, _ := cmd.Flags().GetStringArray(config.FlagCmdCredentialDockerRegistry)
+		if len(registries) == 0 {
+			fmt.Fprintf(os.Stderr, "Use --%s to choose the registries to hand the token to\n", config.FlagCmdCredentialDockerRegistry)
+		}
+		if !isAllowedRegistry(serverURL, registries) {
+			// docker reads helper errors from stdout
+			fmt.Println(errDockerCredentialsNotFound)
+			os.Exit(1)
+		}
+
+		client, token := getCredentialToken()
+		json.NewEncoder(os.Stdout).Encode(dockerCredential{
+			ServerURL: serverURL,
+			Username:  client.ClientId,
+			Secret:    token.AccessToken,
+		})
+	case "store", "erase":
+		ioutil.ReadAll(os.Stdin)
+	case "list":
+		fmt.Println("{}")
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown docker credential helper action %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// isAllowedRegistry reports whether the server URL docker asks about is one of
+// the registries. Both may be given with or without a scheme and path.
+func isAllowedRegistry(serverURL string, registries []string) bool {
+	host := registryHost(serverURL)
+	for _, registry := range registries {
+		if host != "" && strings.EqualFold(host, registryHost(registry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// registryHost returns the host, and port if any, of a registry
+func registryHost(registry string) string {
+	if !strings.Contains(registry, "://") {
+		registry = "https://" + registry
+	}
+	u, err := url.Parse(registry)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,39 @@
+package credential
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	credentialJSONCmd = &cobra.Command{
+		Use:   "json",
+		Short: "Print the token and its expiry as JSON",
+		Long:  "Print {\"token\": ..., \"expiry\": ...} with the expiry in RFC 3339, for tools and scripts of your own.",
+		Args:  cobra.NoArgs,
+		Run:   credentialJSONExecute,
+	}
+)
+
+type jsonCredential struct {
+	Token  string `json:"token"`
+	Expiry string `json:"expiry,omitempty"`
+}
+
+func credentialJSONExecute(cmd *cobra.Command, args []string) {
+	_, token := getCredentialToken()
+
+	credential := jsonCredential{Token: token.AccessToken}
+	if token.ExpiresAt > 0 {
+		credential.Expiry = time.Unix(token.ExpiresAt, 0).UTC().Format(time.RFC3339)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(credential); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not write the credential - %v\n", err)
+		os.Exit(1)
+	}
+}
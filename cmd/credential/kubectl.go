@@ -0,0 +1,66 @@
+package credential
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// kubeExecInfoEnv is the environment variable kubectl describes the exec
+// plugin call in
+const kubeExecInfoEnv = "KUBERNETES_EXEC_INFO"
+
+const defaultKubeAPIVersion = "client.authentication.k8s.io/v1beta1"
+
+var (
+	credentialKubectlCmd = &cobra.Command{
+		Use:   "kubectl",
+		Short: "Print an ExecCredential for kubectl exec plugins",
+		Long: "Print the token as a Kubernetes ExecCredential, for use as the exec command of a user" +
+			" in a kubeconfig.",
+		Args: cobra.NoArgs,
+		Run:  credentialKubectlExecute,
+	}
+)
+
+type execCredential struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Status     *execCredentialStatus `json:"status"`
+}
+
+type execCredentialStatus struct {
+	Token               string `json:"token"`
+	ExpirationTimestamp string `json:"expirationTimestamp,omitempty"`
+}
+
+func credentialKubectlExecute(cmd *cobra.Command, args []string) {
+	_, token := getCredentialToken()
+
+	credential := execCredential{
+		APIVersion: kubeAPIVersion(),
+		Kind:       "ExecCredential",
+		Status:     &execCredentialStatus{Token: token.AccessToken},
+	}
+	// kubectl calls the plugin again once the token expired
+	if token.ExpiresAt > 0 {
+		credential.Status.ExpirationTimestamp = time.Unix(token.ExpiresAt, 0).UTC().Format(time.RFC3339)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(credential); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not write the ExecCredential - %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// kubeAPIVersion answers in the API version kubectl asked for
+func kubeAPIVersion() string {
+	var info execCredential
+	if err := json.Unmarshal([]byte(os.Getenv(kubeExecInfoEnv)), &info); err != nil || info.APIVersion == "" {
+		return defaultKubeAPIVersion
+	}
+	return info.APIVersion
+}
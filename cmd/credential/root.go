@@ -0,0 +1,53 @@
+package credential
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// CredentialCmd groups the credential helpers other tools call to get tokens
+var CredentialCmd = &cobra.Command{
+	Use:   "credential",
+	Short: "Credential helpers that hand tokens to other tools",
+	Long: "Print a token for a client in the format another tool expects from its credential helper." +
+		" The client is taken from --client or " + config.EnvCredentialClient + ".",
+}
+
+func init() {
+	CredentialCmd.PersistentFlags().String(config.FlagCmdCredentialClient, "", config.DescCmdCredentialClient)
+	viper.BindPFlag(config.KeyCmdCredentialClient, CredentialCmd.PersistentFlags().Lookup(config.FlagCmdCredentialClient))
+	CredentialCmd.PersistentFlags().String(config.FlagCmdClientTokenAudience, "", config.DescCmdClientTokenAudience)
+	viper.BindPFlag(config.KeyCmdCredentialAudience, CredentialCmd.PersistentFlags().Lookup(config.FlagCmdClientTokenAudience))
+	CredentialCmd.PersistentFlags().String(config.FlagCmdClientTokenScope, "", config.DescCmdClientTokenScope)
+	viper.BindPFlag(config.KeyCmdCredentialScope, CredentialCmd.PersistentFlags().Lookup(config.FlagCmdClientTokenScope))
+
+	CredentialCmd.AddCommand(credentialKubectlCmd)
+	CredentialCmd.AddCommand(credentialDockerCmd)
+	CredentialCmd.AddCommand(credentialJSONCmd)
+}
+
+// getCredentialToken returns a token for the client the credential helper was
+// called for
+func getCredentialToken() (*config.Client, *common.TokenResponse) {
+	clientName := viper.GetString(config.KeyCmdCredentialClient)
+	if clientName == "" {
+		clientName = os.Getenv(config.EnvCredentialClient)
+	}
+	// the calling tool owns the terminal, there is no one to pick a client
+	if clientName == "" {
+		fmt.Fprintf(os.Stderr, "Use --%s or set %s to choose the client\n", config.FlagCmdCredentialClient, config.EnvCredentialClient)
+		os.Exit(1)
+	}
+
+	client := common.SelectClient(clientName)
+	return client, common.GetTokenWithAgent(client, common.TokenOptions{
+		Audience: viper.GetString(config.KeyCmdCredentialAudience),
+		Scope:    viper.GetString(config.KeyCmdCredentialScope),
+		NoPrompt: true,
+	})
+}
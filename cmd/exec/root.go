@@ -0,0 +1,118 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Environment variables the command is run with
+const (
+	EnvAuth0Domain       = "AUTH0_DOMAIN"
+	EnvAuth0ClientId     = "AUTH0_CLIENT_ID"
+	EnvAuth0ClientSecret = "AUTH0_CLIENT_SECRET"
+)
+
+// ExecCmd runs a command with a token for a client in its environment
+var ExecCmd = &cobra.Command{
+	Use:   "exec -- <command> [args...]",
+	Short: "Run a command with a token for a client in its environment",
+	Long: "Get a token for a client, or reuse a cached one, and run the command with it in " + common.TokenEnvVar +
+		" and the client's tenant domain and client id in " + EnvAuth0Domain + " and " + EnvAuth0ClientId + "." +
+		" With --include-secret the client secret is set in " + EnvAuth0ClientSecret + " as well." +
+		" spsauth0 exits with the exit code of the command.",
+	Args: cobra.MinimumNArgs(1),
+	Run:  execExecute,
+}
+
+func init() {
+	// flags after the command belong to the command
+	ExecCmd.Flags().SetInterspersed(false)
+
+	ExecCmd.Flags().String(config.FlagCmdExecClient, "", config.DescCmdExecClient)
+	viper.BindPFlag(config.KeyCmdExecClient, ExecCmd.Flags().Lookup(config.FlagCmdExecClient))
+	ExecCmd.Flags().String(config.FlagCmdClientTokenAudience, "", config.DescCmdClientTokenAudience)
+	viper.BindPFlag(config.KeyCmdExecAudience, ExecCmd.Flags().Lookup(config.FlagCmdClientTokenAudience))
+	ExecCmd.Flags().String(config.FlagCmdClientTokenScope, "", config.DescCmdClientTokenScope)
+	viper.BindPFlag(config.KeyCmdExecScope, ExecCmd.Flags().Lookup(config.FlagCmdClientTokenScope))
+	ExecCmd.Flags().Bool(config.FlagCmdClientTokenNoCache, false, config.DescCmdClientTokenNoCache)
+	viper.BindPFlag(config.KeyCmdExecNoCache, ExecCmd.Flags().Lookup(config.FlagCmdClientTokenNoCache))
+	ExecCmd.Flags().Bool(config.FlagCmdExecIncludeSecret, false, config.DescCmdExecIncludeSecret)
+	viper.BindPFlag(config.KeyCmdExecIncludeSecret, ExecCmd.Flags().Lookup(config.FlagCmdExecIncludeSecret))
+}
+
+func execExecute(cmd *cobra.Command, args []string) {
+	client := common.SelectClient(viper.GetString(config.KeyCmdExecClient))
+
+	tenantConfig, err := config.LoadTenantConfigWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not load tenant config - %v\n", err)
+		os.Exit(1)
+	}
+	tenant := tenantConfig.GetTenantConfig(strings.ToLower(client.TenantName))
+	if tenant == nil {
+		fmt.Fprintf(os.Stderr, "Tenant %s of client %s is not configured\n", client.TenantName, client.ClientName)
+		os.Exit(1)
+	}
+
+	token := common.GetTokenWithAgent(client, common.TokenOptions{
+		NoCache:  viper.GetBool(config.KeyCmdExecNoCache),
+		Audience: viper.GetString(config.KeyCmdExecAudience),
+		Scope:    viper.GetString(config.KeyCmdExecScope),
+	})
+
+	env := append(os.Environ(),
+		common.TokenEnvVar+"="+token.AccessToken,
+		EnvAuth0Domain+"="+tenant.Tenant.Domain,
+		EnvAuth0ClientId+"="+client.ClientId,
+	)
+	if viper.GetBool(config.KeyCmdExecIncludeSecret) && client.ClientSecret != "" {
+		env = append(env, EnvAuth0ClientSecret+"="+common.ResolveClientSecrets(client).ClientSecret)
+	}
+
+	os.Exit(run(args, env))
+}
+
+// run runs the command with env as its environment and returns its exit code.
+// Interrupts are passed on to the command instead of stopping spsauth0 first.
+func run(args []string, env []string) int {
+	child := osexec.Command(args[0], args[1:]...)
+	child.Env = env
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	if err := child.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not run %s - %v\n", args[0], err)
+		return 1
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			child.Process.Signal(sig)
+		}
+	}()
+
+	err := child.Wait()
+	var exitErr *osexec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s failed - %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ProxyCmd serves a reverse proxy that adds a client's token to every request
+var ProxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Run a local proxy that adds a bearer token to requests",
+	Long: "Forward every request made to the listen address to the upstream API with an Authorization" +
+		" header holding a token for the client. The token is renewed before it expires. Anyone that can" +
+		" reach the listen address can use the token, so keep it on localhost.",
+	Args: cobra.NoArgs,
+	Run:  proxyExecute,
+}
+
+func init() {
+	ProxyCmd.Flags().String(config.FlagCmdProxyClient, "", config.DescCmdProxyClient)
+	viper.BindPFlag(config.KeyCmdProxyClient, ProxyCmd.Flags().Lookup(config.FlagCmdProxyClient))
+	ProxyCmd.Flags().String(config.FlagCmdClientTokenAudience, "", config.DescCmdClientTokenAudience)
+	viper.BindPFlag(config.KeyCmdProxyAudience, ProxyCmd.Flags().Lookup(config.FlagCmdClientTokenAudience))
+	ProxyCmd.Flags().String(config.FlagCmdClientTokenScope, "", config.DescCmdClientTokenScope)
+	viper.BindPFlag(config.KeyCmdProxyScope, ProxyCmd.Flags().Lookup(config.FlagCmdClientTokenScope))
+	ProxyCmd.Flags().String(config.FlagCmdProxyListen, config.DefaultProxyListen, config.DescCmdProxyListen)
+	viper.BindPFlag(config.KeyCmdProxyListen, ProxyCmd.Flags().Lookup(config.FlagCmdProxyListen))
+	ProxyCmd.Flags().String(config.FlagCmdProxyUpstream, "", config.DescCmdProxyUpstream)
+	viper.BindPFlag(config.KeyCmdProxyUpstream, ProxyCmd.Flags().Lookup(config.FlagCmdProxyUpstream))
+}
+
+func proxyExecute(cmd *cobra.Command, args []string) {
+	upstream, err := url.Parse(viper.GetString(config.KeyCmdProxyUpstream))
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		fmt.Fprintf(os.Stderr, "Use --%s to give the URL of the API to forward to\n", config.FlagCmdProxyUpstream)
+		os.Exit(1)
+	}
+
+	client := common.SelectClient(viper.GetString(config.KeyCmdProxyClient))
+	// the first token is fetched up front so any login happens before serving
+	tokens := common.NewTokenSource(client, common.TokenOptions{
+		Audience: viper.GetString(config.KeyCmdProxyAudience),
+		Scope:    viper.GetString(config.KeyCmdProxyScope),
+	})
+
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = upstream.Host
+	}
+	proxy.Transport = &tokenTransport{tokens: tokens, next: http.DefaultTransport}
+	proxy.ModifyResponse = func(res *http.Response) error {
+		fmt.Fprintf(os.Stderr, "%s %s %d\n", res.Request.Method, res.Request.URL, res.StatusCode)
+		return nil
+	}
+	// a token that can not be renewed fails the request, not the proxy
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		fmt.Fprintf(os.Stderr, "%s %s %d - %v\n", r.Method, r.URL, http.StatusBadGateway, err)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	listen := viper.GetString(config.KeyCmdProxyListen)
+	fmt.Fprintf(os.Stderr, "Forwarding %s to %s with tokens for %s\n", listen, upstream, client.ClientName)
+	if err := http.ListenAndServe(listen, proxy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: proxy stopped - %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// tokenTransport adds the bearer token to every request it forwards
+type tokenTransport struct {
+	tokens *common.TokenSource
+	next   http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return t.next.RoundTrip(r)
+}
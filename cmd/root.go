@@ -17,8 +17,14 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/bluce-clj/spsauth0/cmd/agent"
 	"github.com/bluce-clj/spsauth0/cmd/client"
+	"github.com/bluce-clj/spsauth0/cmd/credential"
+	"github.com/bluce-clj/spsauth0/cmd/exec"
+	"github.com/bluce-clj/spsauth0/cmd/proxy"
+	"github.com/bluce-clj/spsauth0/cmd/secret"
 	"github.com/bluce-clj/spsauth0/cmd/tenant"
+	"github.com/bluce-clj/spsauth0/cmd/token"
 	"github.com/bluce-clj/spsauth0/internal/config"
 	"github.com/spf13/cobra"
 	"os"
@@ -71,6 +77,12 @@ func init() {
 	// Set up awscred Commands
 	rootCmd.AddCommand(tenant.TenantCmd)
 	rootCmd.AddCommand(client.ClientCmd)
+	rootCmd.AddCommand(token.TokenCmd)
+	rootCmd.AddCommand(exec.ExecCmd)
+	rootCmd.AddCommand(proxy.ProxyCmd)
+	rootCmd.AddCommand(credential.CredentialCmd)
+	rootCmd.AddCommand(agent.AgentCmd)
+	rootCmd.AddCommand(secret.SecretCmd)
 	rootCmd.AddCommand()
 
 	cobra.OnInitialize(initConfig)
@@ -0,0 +1,242 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// SecretCmd manages where client secrets are kept
+var SecretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Keep client secrets out of the config files",
+	Long: "Move client secrets and test user passwords into an encrypted vault or the OS keyring." +
+		" The config files then only refer to them. The vault is encrypted with a key derived from a" +
+		" passphrase, which commands ask for while it is locked or read from " + config.EnvVaultPassphrase + "." +
+		" The key is never written to disk, an unlocked vault's key is kept by the agent.",
+}
+
+var (
+	secretInitCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Move the secrets of all clients into the vault or keyring",
+		Long: "Create the vault, or check the keyring can be used, and move the secrets in the client and" +
+			" tenant configs into it. Clients added later store their secrets there as well." +
+			" Running it again moves secrets added to the config files by hand.",
+		Args: cobra.NoArgs,
+		Run:  secretInitExecute,
+	}
+
+	secretUnlockCmd = &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock the vault for the commands that follow",
+		Long: "Hand the vault key to the running agent, which keeps it in memory and reads secrets from the" +
+			" vault for the commands that follow until the timeout passes, the vault is locked or the agent" +
+			" stops. The key itself never leaves the agent." +
+			" Start the agent first with 'spsauth0 agent start'.",
+		Args: cobra.NoArgs,
+		Run:  secretUnlockExecute,
+	}
+
+	secretLockCmd = &cobra.Command{
+		Use:   "lock",
+		Short: "Lock the vault again",
+		Long:  "Have the agent forget the vault key. It keeps the secrets it already read until it is stopped.",
+		Args:  cobra.NoArgs,
+		Run:   secretLockExecute,
+	}
+
+	secretStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show where secrets are kept and whether the vault is unlocked",
+		Args:  cobra.NoArgs,
+		Run:   secretStatusExecute,
+	}
+)
+
+func init() {
+	secretInitCmd.Flags().String(config.FlagCmdSecretInitBackend, config.SecretBackendVault, config.DescCmdSecretInitBackend)
+	viper.BindPFlag(config.KeyCmdSecretInitBackend, secretInitCmd.Flags().Lookup(config.FlagCmdSecretInitBackend))
+	secretUnlockCmd.Flags().Duration(config.FlagCmdSecretUnlockTimeout, config.DefaultSecretUnlockTimeout, config.DescCmdSecretUnlockTimeout)
+	viper.BindPFlag(config.KeyCmdSecretUnlockTimeout, secretUnlockCmd.Flags().Lookup(config.FlagCmdSecretUnlockTimeout))
+
+	SecretCmd.AddCommand(secretInitCmd)
+	SecretCmd.AddCommand(secretUnlockCmd)
+	SecretCmd.AddCommand(secretLockCmd)
+	SecretCmd.AddCommand(secretStatusCmd)
+}
+
+func secretInitExecute(cmd *cobra.Command, args []string) {
+	backend := viper.GetString(config.KeyCmdSecretInitBackend)
+	current, err := config.GetSecretBackend()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not load the secret store config - %v\n", err)
+		os.Exit(1)
+	}
+	if current != "" && current != backend {
+		fmt.Fprintf(os.Stderr, "Secrets are already kept in the %s, moving them to the %s is not supported\n", current, backend)
+		os.Exit(1)
+	}
+
+	switch backend {
+	case config.SecretBackendVault:
+		if config.VaultExists() {
+			// the secrets moved in below are encrypted with the existing key
+			if _, err := config.VaultSecretCount(); err == config.ErrVaultLocked {
+				common.UnlockVault()
+			}
+		} else {
+			createVault()
+		}
+	case config.SecretBackendKeyring:
+		if err := config.CheckKeyring(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: the OS keyring can not be used - %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported secret backend %s, use one of %v\n", backend, config.GetSupportedSecretBackends())
+		os.Exit(1)
+	}
+
+	if err := config.SetSecretBackend(backend); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not save the secret store config - %v\n", err)
+		os.Exit(1)
+	}
+
+	clientConfig, err := config.LoadClientConfigWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not load client config - %v\n", err)
+		os.Exit(1)
+	}
+	movedClients, err := clientConfig.StoreSecrets()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := clientConfig.SaveClientConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not save client config - %v\n", err)
+		os.Exit(1)
+	}
+
+	tenantConfig, err := config.LoadTenantConfigWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not load tenant config - %v\n", err)
+		os.Exit(1)
+	}
+	movedTenants, err := tenantConfig.StoreSecrets()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tenantConfig.SaveTenantConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not save tenant config - %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Moved %d secrets into the %s\n", movedClients+movedTenants, backend)
+}
+
+// createVault asks for the passphrase of a new vault, twice
+func createVault() {
+	passphrase, err := common.PromptPassword("New vault passphrase")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	repeated, err := common.PromptPassword("Repeat the passphrase")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if passphrase != repeated {
+		fmt.Fprintln(os.Stderr, "The passphrases do not match")
+		os.Exit(1)
+	}
+
+	if err := config.CreateVault(passphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not create the vault - %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func secretUnlockExecute(cmd *cobra.Command, args []string) {
+	if !config.VaultExists() {
+		fmt.Fprintln(os.Stderr, "There is no vault to unlock, use 'spsauth0 secret init' to create one.")
+		os.Exit(1)
+	}
+
+	// the key only lives in the agent's memory, without it there is nothing
+	// to keep the vault unlocked
+	if _, err := common.GetAgentStatus(); err != nil {
+		fmt.Fprintln(os.Stderr, "The agent is not running, start it with 'spsauth0 agent start' to keep the vault unlocked.")
+		os.Exit(1)
+	}
+
+	passphrase, err := common.PromptPassword("Vault passphrase")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	key, err := config.UnlockVault(passphrase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var until time.Time
+	if timeout := viper.GetDuration(config.KeyCmdSecretUnlockTimeout); timeout > 0 {
+		until = time.Now().Add(timeout)
+	}
+	if err := common.UnlockAgentVault(key, until); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not hand the vault key to the agent - %v\n", err)
+		os.Exit(1)
+	}
+	if until.IsZero() {
+		fmt.Println("The vault is unlocked until the agent stops")
+		return
+	}
+	fmt.Printf("The vault is unlocked until %s\n", until.Format(time.RFC3339))
+}
+
+func secretLockExecute(cmd *cobra.Command, args []string) {
+	err := common.LockAgentVault()
+	if err != nil && !common.IsAgentNotRunning(err) {
+		fmt.Fprintf(os.Stderr, "Error: could not lock the vault - %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("The vault is locked")
+	if err == nil {
+		fmt.Println("The agent keeps the secrets it already read, run 'spsauth0 agent stop' to drop them")
+	}
+}
+
+func secretStatusExecute(cmd *cobra.Command, args []string) {
+	backend, err := config.GetSecretBackend()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not load the secret store config - %v\n", err)
+		os.Exit(1)
+	}
+
+	switch backend {
+	case "":
+		fmt.Println("Secrets are kept in the config files, use 'spsauth0 secret init' to move them")
+	case config.SecretBackendKeyring:
+		fmt.Println("Secrets are kept in the OS keyring")
+	case config.SecretBackendVault:
+		fmt.Println("Secrets are kept in the vault")
+		status, err := common.GetAgentStatus()
+		switch {
+		case err != nil || !status.VaultUnlocked:
+			fmt.Println("It is locked")
+		case status.VaultUnlockedUntil == 0:
+			fmt.Println("It is unlocked until the agent stops")
+		default:
+			fmt.Printf("It is unlocked until %s\n", time.Unix(status.VaultUnlockedUntil, 0).Format(time.RFC3339))
+		}
+	}
+}
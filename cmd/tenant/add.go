@@ -57,6 +57,7 @@ func tenantAddExecute(cmd *cobra.Command, args []string) {
 			APIs:  getTenantAPIs(),
 		},
 	}
+	newTenant.Tenant.DefaultAudience = getDefaultAudience(newTenant.Tenant.APIs)
 
 	// Save tenant to config
 	tenantConfig.SetTenant(tenantName, newTenant)
@@ -82,7 +83,12 @@ func getTenantAPIs()  []config.API{
 			fmt.Println("Failed to get tenant API configuration: ", err)
 			os.Exit(1)
 		}
-		apilist = append(apilist, config.API{Name: apiName, Audience: apiAudience})
+		apiPermissions, err := common.PromptOptionalString("Tenant API permissions (space separated, empty for none): ", "")
+		if err != nil {
+			fmt.Println("Failed to get tenant API configuration: ", err)
+			os.Exit(1)
+		}
+		apilist = append(apilist, config.API{Name: apiName, Audience: apiAudience, Permissions: strings.Fields(apiPermissions)})
 		_, addAnotherAPI, err := common.PromptSelect("Do you have more APIs to add to this tenant?", []string{"Yes", "No"})
 		if addAnotherAPI == "No" {
 			break
@@ -92,6 +98,28 @@ func getTenantAPIs()  []config.API{
 	return apilist
 }
 
+// getDefaultAudience asks which of the tenant's APIs tokens are requested for
+// when the client does not name one
+func getDefaultAudience(apis []config.API) string {
+	if len(apis) == 0 {
+		return ""
+	}
+
+	names := []string{"None (pick one for every token)"}
+	for _, api := range apis {
+		names = append(names, api.Name)
+	}
+	i, _, err := common.PromptSelect("Select the Default API of this tenant", names)
+	if err != nil {
+		fmt.Println("Failed to get the default API: ", err)
+		os.Exit(1)
+	}
+	if i == 0 {
+		return ""
+	}
+	return apis[i-1].Audience
+}
+
 func getDefaultClient() *config.Client {
 	// Get configured clients
 	clientConfig, err := config.LoadClientConfigWithViper()
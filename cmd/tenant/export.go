@@ -55,7 +55,7 @@ func tenantExportExecute(cmd *cobra.Command, args []string)  {
 	//	os.Exit(1)
 	//}
 
-	client := getClientForExport(tenant)
+	client := common.ResolveClientSecrets(getClientForExport(tenant))
 
 	cfg := &config.Auth0Config{
 		Auth0Domain: tenant.Tenant.Domain,
@@ -88,7 +88,7 @@ func tenantSearchExecute(cmd *cobra.Command, args []string) {
 		fmt.Println(err.Error())
 	}
 	tenant := tenantConfig.GetTenantConfig(strings.ToLower(tenantName))
-	client := getClientForSearch(tenant, tenantName)
+	client := common.ResolveClientSecrets(getClientForSearch(tenant, tenantName))
 
 	auth0 := Auth0Connector{
 		Auth0Domain:   tenant.Tenant.Domain,
@@ -96,6 +96,7 @@ func tenantSearchExecute(cmd *cobra.Command, args []string) {
 		RetryInterval: Auth0RetryInternal,
 		MaxRetries:    uint64(MaxAuth0Retries),
 		ClientToUse:   client,
+		Tenant:        tenant,
 	}
 
 	// Handle Error
@@ -164,7 +165,10 @@ func getClientForSearch(tenant *config.Tenant, tenantName string) *config.Client
 }
 
 func getTokenHeaderVal(auth0 Auth0Connector) (string, error) {
-	token := common.GetTokenHandler(auth0.ClientToUse)
+	// the search calls the management API, whatever the client's default audience is
+	token := common.GetTokenHandler(auth0.ClientToUse, common.TokenOptions{
+		Audience: common.TenantBaseURL(auth0.Tenant) + "/api/v2/",
+	})
 
 	return "Bearer " + token, nil
 }
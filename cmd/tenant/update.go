@@ -45,10 +45,11 @@ func tenantUpdateExecute(cmd *cobra.Command, args []string)  {
 
 	newTenant := &config.Tenant{
 		Tenant: config.TenantProfile{
-			Name:          tenantName,
-			Domain:        domain,
-			APIs: tenant.Tenant.APIs,
-			DefaultClient: getDefaultClient(),
+			Name:            tenantName,
+			Domain:          domain,
+			APIs:            tenant.Tenant.APIs,
+			DefaultClient:   getDefaultClient(),
+			DefaultAudience: getDefaultAudience(tenant.Tenant.APIs),
 		},
 	}
 
@@ -60,4 +61,13 @@ func tenantUpdateExecute(cmd *cobra.Command, args []string)  {
 		fmt.Println("Failed to save tenant configuration: ", err)
 		os.Exit(1)
 	}
+	// endpoints discovered on the old domain are of no use any more
+	if !strings.EqualFold(domain, tenant.Tenant.Domain) {
+		err := config.UpdateDiscoveryCacheWithViper(func(discoveryCache *config.DiscoveryCache) {
+			discoveryCache.DeleteConfiguration(tenantName)
+		})
+		if err != nil {
+			fmt.Printf("Warning: could not save discovery cache - %v\n", err)
+		}
+	}
 }
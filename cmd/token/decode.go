@@ -0,0 +1,98 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	tokenDecodeCmd = &cobra.Command{
+		Use:   "decode [token]",
+		Short: "Decode a JWT and print its header and claims",
+		Long: "Decode a JWT without verifying it. The token is read from the argument, from stdin" +
+			" or, with --client, from the last token cached for that client.",
+		Args: cobra.MaximumNArgs(1),
+		Run:  tokenDecodeExecute,
+	}
+)
+
+func init() {
+	tokenDecodeCmd.Flags().String(config.FlagCmdTokenClient, "", config.DescCmdTokenClient)
+	viper.BindPFlag(config.KeyCmdTokenDecodeClient, tokenDecodeCmd.Flags().Lookup(config.FlagCmdTokenClient))
+}
+
+func tokenDecodeExecute(cmd *cobra.Command, args []string) {
+	jwt, err := common.DecodeJWT(getTokenArg(args, viper.GetString(config.KeyCmdTokenDecodeClient)))
+	if err != nil {
+		fmt.Printf("Error: could not decode token - %v\n", err)
+		os.Exit(1)
+	}
+
+	header, _ := json.MarshalIndent(jwt.Header, "", "  ")
+	claims, _ := json.MarshalIndent(jwt.Claims, "", "  ")
+	fmt.Printf("Header:\n%s\n\nClaims:\n%s\n\n", header, claims)
+
+	printTimeClaim(jwt, "iat", "Issued At")
+	printTimeClaim(jwt, "nbf", "Not Before")
+	printTimeClaim(jwt, "exp", "Expires At")
+
+	printConfigMatch(jwt)
+}
+
+func printTimeClaim(jwt *common.JWT, claim string, label string) {
+	t, ok := jwt.TimeClaim(claim)
+	if !ok {
+		return
+	}
+	fmt.Printf("%-11s %s (%s)\n", label+":", t.Local().Format(time.RFC1123), describeTimeRemaining(t))
+}
+
+// describeTimeRemaining describes t relative to now, e.g. "in 59m0s" or "3m0s ago"
+func describeTimeRemaining(t time.Time) string {
+	d := time.Until(t).Round(time.Second)
+	if d == 0 {
+		return "now"
+	}
+	if d < 0 {
+		return fmt.Sprintf("%s ago", -d)
+	}
+	return fmt.Sprintf("in %s", d)
+}
+
+// printConfigMatch prints the configured tenant and client the token was
+// issued by and for, based on its iss and azp claims.
+func printConfigMatch(jwt *common.JWT) {
+	fmt.Println()
+
+	tenantConfig, err := config.LoadTenantConfigWithViper()
+	if err != nil {
+		fmt.Printf("Error: could not load tenant config - %v\n", err)
+		os.Exit(1)
+	}
+
+	if tenant := tenantConfig.GetTenantByDomain(jwt.StringClaim("iss")); tenant != nil {
+		fmt.Printf("Tenant: %s (%s)\n", tenant.Tenant.Name, tenant.Tenant.Domain)
+	} else {
+		fmt.Printf("Tenant: no configured tenant matches issuer %s\n", jwt.StringClaim("iss"))
+	}
+
+	clientConfig, err := config.LoadClientConfigWithViper()
+	if err != nil {
+		fmt.Printf("Error: could not load client config - %v\n", err)
+		os.Exit(1)
+	}
+
+	clientId := jwt.StringClaim("azp")
+	if client := clientConfig.GetClientByClientId(clientId); client != nil {
+		fmt.Printf("Client: %s (%s)\n", client.ClientName, client.ClientType)
+	} else {
+		fmt.Printf("Client: no configured client matches azp %s\n", clientId)
+	}
+}
@@ -0,0 +1,125 @@
+package token
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	tokenRevokeCmd = &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke stored refresh tokens and forget cached tokens",
+		Long: "Revoke the refresh tokens spsauth0 stored for a client, or for every client of a tenant," +
+			" at the tenant's revocation endpoint and remove them along with the cached access tokens." +
+			" --username narrows them down to the tokens of one password flow test user." +
+			" Access tokens cannot be revoked and stay valid until they expire.",
+		Args: cobra.NoArgs,
+		Run:  tokenRevokeExecute,
+	}
+)
+
+func init() {
+	tokenRevokeCmd.Flags().String(config.FlagCmdTokenRevokeTenant, "", config.DescCmdTokenRevokeTenant)
+	viper.BindPFlag(config.KeyCmdTokenRevokeTenant, tokenRevokeCmd.Flags().Lookup(config.FlagCmdTokenRevokeTenant))
+	tokenRevokeCmd.Flags().String(config.FlagCmdTokenRevokeClient, "", config.DescCmdTokenRevokeClient)
+	viper.BindPFlag(config.KeyCmdTokenRevokeClient, tokenRevokeCmd.Flags().Lookup(config.FlagCmdTokenRevokeClient))
+	tokenRevokeCmd.Flags().String(config.FlagCmdTokenRevokeUsername, "", config.DescCmdTokenRevokeUsername)
+	viper.BindPFlag(config.KeyCmdTokenRevokeUsername, tokenRevokeCmd.Flags().Lookup(config.FlagCmdTokenRevokeUsername))
+}
+
+func tokenRevokeExecute(cmd *cobra.Command, args []string) {
+	tenantName := viper.GetString(config.KeyCmdTokenRevokeTenant)
+	clientName := viper.GetString(config.KeyCmdTokenRevokeClient)
+	username := viper.GetString(config.KeyCmdTokenRevokeUsername)
+	if tenantName == "" && clientName == "" {
+		fmt.Println("Use --client or --tenant to choose the tokens to revoke")
+		os.Exit(1)
+	}
+
+	clientConfig, err := config.LoadClientConfigWithViper()
+	if err != nil {
+		fmt.Printf("Error: could not load client config - %v\n", err)
+		os.Exit(1)
+	}
+	store, err := config.LoadRefreshTokenStoreWithViper()
+	if err != nil {
+		fmt.Printf("Error: could not load refresh tokens - %v\n", err)
+		os.Exit(1)
+	}
+
+	// the tokens are revoked before taking the lock to remove them, other
+	// commands need not wait for the revocation requests
+	failed := 0
+	revoked := make([]*config.StoredRefreshToken, 0)
+	for _, stored := range store.GetRefreshTokens(tenantName, clientName, username) {
+		owner := stored.ClientName
+		if stored.Username != "" {
+			owner += " as " + stored.Username
+		}
+		client := clientConfig.GetClientConfig(strings.ToLower(stored.ClientName))
+		if client == nil {
+			fmt.Printf("Could not revoke the refresh token of %s (%s): the client is no longer configured\n", owner, stored.TenantName)
+			failed++
+			continue
+		}
+
+		if err := common.RevokeRefreshToken(client, stored.RefreshToken); err != nil {
+			fmt.Printf("Could not revoke the refresh token of %s (%s): %v\n", owner, stored.TenantName, err)
+			failed++
+			continue
+		}
+		revoked = append(revoked, stored)
+		fmt.Printf("Revoked the refresh token of %s (%s)\n", owner, stored.TenantName)
+	}
+
+	err = config.UpdateRefreshTokenStoreWithViper(func(store *config.RefreshTokenStore) {
+		for _, stored := range revoked {
+			// leave a token another login stored in the meantime alone
+			current := store.GetRefreshToken(stored.TenantName, stored.ClientName, stored.Audience, stored.Scope, stored.Params, stored.Username)
+			if current != nil && current.RefreshToken == stored.RefreshToken {
+				store.DeleteRefreshToken(current)
+			}
+		}
+	})
+	if err != nil {
+		fmt.Println("Failed to save refresh tokens: ", err)
+		os.Exit(1)
+	}
+	for _, stored := range revoked {
+		if err := common.DeleteSecret(stored.RefreshToken); err != nil {
+			fmt.Printf("Could not remove the refresh token of %s (%s) from the secret store: %v\n", stored.ClientName, stored.TenantName, err)
+		}
+	}
+
+	// access tokens cannot be revoked, forgetting them is all there is to do
+	purged := 0
+	err = config.UpdateTokenCacheWithViper(func(tokenCache *config.TokenCache) {
+		purged = tokenCache.Purge(tenantName, clientName, username)
+	})
+	if err != nil {
+		fmt.Println("Failed to save token cache: ", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed %d cached access token(s)\n", purged)
+
+	// a running agent holds its own copies, it would keep serving them
+	flushed, err := common.FlushAgent(tenantName, clientName)
+	switch {
+	case common.IsAgentNotRunning(err):
+	case err != nil:
+		fmt.Printf("Could not remove the tokens held by the agent: %v\n", err)
+		failed++
+	default:
+		fmt.Printf("Removed %d token(s) held by the agent\n", flushed)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,19 @@
+package token
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// TokenCmd represents the token command
+var TokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Inspect tokens issued by auth0",
+	Long: "Inspect tokens issued by auth0. Tokens can be given as an argument, piped in on stdin" +
+		" or taken from the token cache of a configured client.",
+}
+
+func init() {
+	TokenCmd.AddCommand(tokenDecodeCmd)
+	TokenCmd.AddCommand(tokenVerifyCmd)
+	TokenCmd.AddCommand(tokenRevokeCmd)
+}
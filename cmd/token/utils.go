@@ -0,0 +1,46 @@
+package token
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// getTokenArg returns the raw token to work on. It is taken from the first
+// argument, from the token cache when clientName is set, or otherwise from stdin.
+func getTokenArg(args []string, clientName string) string {
+	if len(args) == 1 && args[0] != "-" {
+		return args[0]
+	}
+
+	if clientName != "" {
+		tokenCache, err := config.LoadTokenCacheWithViper()
+		if err != nil {
+			fmt.Printf("Error: could not load token cache - %v\n", err)
+			os.Exit(1)
+		}
+
+		cached := tokenCache.GetLatestToken(clientName)
+		if cached == nil {
+			fmt.Printf("There is no cached token for client %s, use 'spsauth0 client token' to get one.\n", clientName)
+			os.Exit(1)
+		}
+		return cached.AccessToken
+	}
+
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Printf("Error: could not read token from stdin - %v\n", err)
+		os.Exit(1)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		fmt.Println("No token given, pass one as an argument, on stdin or use --client.")
+		os.Exit(1)
+	}
+	return token
+}
@@ -0,0 +1,128 @@
+package token
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bluce-clj/spsauth0/common"
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	tokenVerifyCmd = &cobra.Command{
+		Use:   "verify [token]",
+		Short: "Verify a JWT against a tenant's signing keys",
+		Long: "Verify the signature of a JWT against the tenant's /.well-known/jwks.json and check" +
+			" its issuer, audience and expiry the way an API would. Use --jwks to verify offline.",
+		Args: cobra.MaximumNArgs(1),
+		Run:  tokenVerifyExecute,
+	}
+)
+
+func init() {
+	tokenVerifyCmd.Flags().String(config.FlagCmdTokenClient, "", config.DescCmdTokenClient)
+	viper.BindPFlag(config.KeyCmdTokenVerifyClient, tokenVerifyCmd.Flags().Lookup(config.FlagCmdTokenClient))
+	tokenVerifyCmd.Flags().String(config.FlagCmdTokenVerifyTenant, "", config.DescCmdTokenVerifyTenant)
+	viper.BindPFlag(config.KeyCmdTokenVerifyTenant, tokenVerifyCmd.Flags().Lookup(config.FlagCmdTokenVerifyTenant))
+	tokenVerifyCmd.Flags().String(config.FlagCmdTokenVerifyJWKS, "", config.DescCmdTokenVerifyJWKS)
+	viper.BindPFlag(config.KeyCmdTokenVerifyJWKS, tokenVerifyCmd.Flags().Lookup(config.FlagCmdTokenVerifyJWKS))
+	tokenVerifyCmd.Flags().Duration(config.FlagCmdTokenVerifySkew, config.DefaultTokenVerifySkew, config.DescCmdTokenVerifySkew)
+	viper.BindPFlag(config.KeyCmdTokenVerifySkew, tokenVerifyCmd.Flags().Lookup(config.FlagCmdTokenVerifySkew))
+}
+
+func tokenVerifyExecute(cmd *cobra.Command, args []string) {
+	jwt, err := common.DecodeJWT(getTokenArg(args, viper.GetString(config.KeyCmdTokenVerifyClient)))
+	if err != nil {
+		fmt.Printf("Error: could not decode token - %v\n", err)
+		os.Exit(1)
+	}
+
+	tenant := getVerifyTenant(jwt)
+
+	// a JWKS file means no network, so the issuer is the one auth0 uses for
+	// the tenant's domain instead of the discovered one
+	var jwks *common.JWKS
+	var issuer string
+	if jwksFile := viper.GetString(config.KeyCmdTokenVerifyJWKS); jwksFile != "" {
+		jwks, err = common.LoadJWKSFile(jwksFile)
+		issuer = common.TenantBaseURL(tenant) + "/"
+	} else {
+		endpoints := common.GetTenantEndpoints(tenant)
+		jwks, err = common.FetchJWKS(endpoints.JwksURI)
+		issuer = endpoints.Issuer
+	}
+	if err != nil {
+		fmt.Printf("Error: could not load JWKS - %v\n", err)
+		os.Exit(1)
+	}
+
+	passed := true
+	check := func(name string, err error) {
+		if err != nil {
+			passed = false
+			fmt.Printf("%-10s FAILED - %v\n", name+":", err)
+			return
+		}
+		fmt.Printf("%-10s ok\n", name+":")
+	}
+
+	check("Signature", jwt.VerifySignature(jwks))
+	check("Issuer", validateIssuer(jwt, issuer))
+	check("Audience", validateAudience(jwt, tenant))
+	check("Expiry", jwt.ValidateTimes(viper.GetDuration(config.KeyCmdTokenVerifySkew)))
+
+	if !passed {
+		os.Exit(1)
+	}
+}
+
+// getVerifyTenant returns the tenant given with --tenant or, without it, the
+// configured tenant whose domain matches the iss claim.
+func getVerifyTenant(jwt *common.JWT) *config.Tenant {
+	tenantConfig, err := config.LoadTenantConfigWithViper()
+	if err != nil {
+		fmt.Printf("Error: could not load tenant config - %v\n", err)
+		os.Exit(1)
+	}
+
+	if tenantName := viper.GetString(config.KeyCmdTokenVerifyTenant); tenantName != "" {
+		tenant := tenantConfig.GetTenantConfig(strings.ToLower(tenantName))
+		if tenant == nil {
+			fmt.Printf("Tenant %s does not exist, use 'spsauth0 tenant list' to list configured tenants.\n", tenantName)
+			os.Exit(1)
+		}
+		return tenant
+	}
+
+	tenant := tenantConfig.GetTenantByDomain(jwt.StringClaim("iss"))
+	if tenant == nil {
+		fmt.Printf("No configured tenant matches issuer %s, use --tenant to pick one.\n", jwt.StringClaim("iss"))
+		os.Exit(1)
+	}
+	return tenant
+}
+
+func validateIssuer(jwt *common.JWT, issuer string) error {
+	if jwt.StringClaim("iss") != issuer {
+		return fmt.Errorf("issuer %q is not %q", jwt.StringClaim("iss"), issuer)
+	}
+	return nil
+}
+
+func validateAudience(jwt *common.JWT, tenant *config.Tenant) error {
+	if len(tenant.Tenant.APIs) == 0 {
+		return fmt.Errorf("tenant %s has no APIs configured to check the audience against", tenant.Tenant.Name)
+	}
+
+	for _, aud := range jwt.Audiences() {
+		for _, api := range tenant.Tenant.APIs {
+			if aud == api.Audience {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("audience %v is not one of the %s tenant's APIs", jwt.Audiences(), tenant.Tenant.Name)
+}
@@ -0,0 +1,620 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// Actions the agent answers on its socket
+const (
+	agentActionToken  = "token"
+	agentActionStatus = "status"
+	agentActionStop   = "stop"
+	agentActionFlush  = "flush"
+	// the vault key is handed to the agent by unlock and never leaves it,
+	// commands that find the vault locked have it read, store and delete the
+	// secrets they need
+	agentActionUnlock       = "unlock"
+	agentActionLock         = "lock"
+	agentActionSecret       = "secret"
+	agentActionStoreSecret  = "storesecret"
+	agentActionDeleteSecret = "deletesecret"
+)
+
+// agentRenewInterval is how often the agent looks for tokens to renew
+const agentRenewInterval = 15 * time.Second
+
+var (
+	// errAgentNotRunning means nothing listens on the agent socket
+	errAgentNotRunning = errors.New("the agent is not running")
+	// errAgentNeedsUser means the token takes a login the agent can not do
+	errAgentNeedsUser = errors.New("the token needs a login")
+)
+
+// agentRequest is what a command sends the agent. The audience and scope are
+// settled by the command, the agent never prompts.
+type agentRequest struct {
+	Action   string
+	Tenant   string       `json:",omitempty"`
+	Client   string       `json:",omitempty"`
+	Audience string       `json:",omitempty"`
+	Scope    string       `json:",omitempty"`
+	Opts     TokenOptions `json:",omitempty"`
+	// VaultKey is unlocked until VaultUntil, forever when it is 0
+	VaultKey   []byte `json:",omitempty"`
+	VaultUntil int64  `json:",omitempty"`
+	// Secret is the vault reference, like vault:clients/ui/password, of the
+	// secret to read or delete, or the secret to store under SecretKey
+	Secret    string `json:",omitempty"`
+	SecretKey string `json:",omitempty"`
+}
+
+type agentResponse struct {
+	Token     *TokenResponse `json:",omitempty"`
+	Status    *AgentStatus   `json:",omitempty"`
+	NeedsUser bool           `json:",omitempty"`
+	Flushed   int            `json:",omitempty"`
+	Secret    string         `json:",omitempty"`
+	Error     string         `json:",omitempty"`
+}
+
+// AgentStatus describes a running agent and the tokens it holds
+type AgentStatus struct {
+	Pid     int
+	Started int64
+	Tokens  []AgentToken
+	// VaultUnlocked tells whether the agent holds the vault key, until
+	// VaultUnlockedUntil unless that is 0
+	VaultUnlocked      bool
+	VaultUnlockedUntil int64
+}
+
+// AgentToken is a token the agent keeps renewed
+type AgentToken struct {
+	TenantName string
+	ClientName string
+	Username   string
+	Audience   string
+	Scope      string
+	ExpiresAt  int64
+}
+
+// agentEntry is a token the agent holds along with what it takes to renew it.
+// The refresh token and token are guarded by the agent's mutex.
+type agentEntry struct {
+	key       string
+	client    *config.Client
+	username  string
+	endpoints *config.OIDCConfiguration
+	audience  string
+	scope     string
+	params    url.Values
+
+	// renewing is held while the token is renewed so a rotated refresh token
+	// is never exchanged twice
+	renewing     sync.Mutex
+	refreshToken string
+	token        *TokenResponse
+}
+
+// Agent holds client secrets, refresh tokens and access tokens in memory and
+// renews the access tokens ahead of their expiry. Commands get tokens from it
+// over a Unix socket, see GetTokenWithAgent. Logins that need the user are
+// left to the command; the agent picks up the refresh token they store.
+type Agent struct {
+	mu       sync.Mutex
+	clients  map[string]*config.Client
+	entries  map[string]*agentEntry
+	started  time.Time
+	listener net.Listener
+}
+
+func NewAgent() *Agent {
+	return &Agent{
+		clients: make(map[string]*config.Client),
+		entries: make(map[string]*agentEntry),
+		started: time.Now(),
+	}
+}
+
+// AgentSocketPath returns the path of the agent socket in the config dir
+func AgentSocketPath() (string, error) {
+	rootConfigDir, err := config.InitConfigDirWithViper()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(rootConfigDir, config.AgentSocketFile), nil
+}
+
+// Serve answers requests on l until the agent is stopped
+func (a *Agent) Serve(l net.Listener) error {
+	a.listener = l
+
+	done := make(chan struct{})
+	defer close(done)
+	go a.renewLoop(done)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Temporary() {
+				continue
+			}
+			// the listener was closed by a stop request
+			return nil
+		}
+		go a.handle(conn)
+	}
+}
+
+// Stop closes the socket, Serve returns once it is closed
+func (a *Agent) Stop() {
+	if a.listener != nil {
+		a.listener.Close()
+	}
+}
+
+func (a *Agent) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req agentRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(agentResponse{Error: fmt.Sprintf("could not read the request - %v", err)})
+		return
+	}
+
+	var res agentResponse
+	switch req.Action {
+	case agentActionToken:
+		token, err := a.token(req)
+		switch {
+		case err == errAgentNeedsUser:
+			res.NeedsUser = true
+		case err != nil:
+			res.Error = err.Error()
+		default:
+			res.Token = token
+		}
+	case agentActionStatus:
+		res.Status = a.status()
+	case agentActionStop:
+		res.Status = a.status()
+		defer a.Stop()
+	case agentActionFlush:
+		res.Flushed = a.flush(req.Tenant, req.Client)
+	case agentActionUnlock:
+		var until time.Time
+		if req.VaultUntil > 0 {
+			until = time.Unix(req.VaultUntil, 0)
+		}
+		if err := config.UseVaultKey(req.VaultKey, until); err != nil {
+			res.Error = err.Error()
+		}
+	case agentActionLock:
+		config.LockVault()
+	case agentActionSecret, agentActionStoreSecret, agentActionDeleteSecret:
+		// only secrets of the vault it holds the key of are the agent's to
+		// hand out, and only while it is unlocked
+		if key, _ := config.VaultKey(); key == nil {
+			res.Error = config.ErrVaultLocked.Error()
+			break
+		}
+		var err error
+		switch {
+		case req.Action == agentActionStoreSecret:
+			res.Secret, err = config.StoreSecret(req.SecretKey, req.Secret)
+		case !strings.HasPrefix(req.Secret, config.SecretBackendVault+":"):
+			err = fmt.Errorf("%s is not a reference to a secret in the vault", req.Secret)
+		case req.Action == agentActionSecret:
+			res.Secret, err = config.ResolveSecret(req.Secret)
+		default:
+			err = config.DeleteSecret(req.Secret)
+		}
+		if err != nil {
+			res.Error = err.Error()
+		}
+	default:
+		res.Error = fmt.Sprintf("unknown action %s", req.Action)
+	}
+	json.NewEncoder(conn).Encode(res)
+}
+
+// token returns a valid token for the request, renewing it if needed. Nothing
+// that talks to auth0 runs under the agent's mutex so one slow tenant does
+// not hold up the requests for the others.
+func (a *Agent) token(req agentRequest) (*TokenResponse, error) {
+	client, err := a.client(req.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := parseLoginParams(client, req.Opts)
+	if err != nil {
+		return nil, err
+	}
+	username := testUsername(client, req.Opts)
+	key := config.TokenCacheKey(client.TenantName, client.ClientName, req.Audience, req.Scope, params.Encode(), username)
+
+	a.mu.Lock()
+	entry := a.entries[key]
+	a.mu.Unlock()
+	if entry == nil {
+		_, tenant, err := findClientTenant(client)
+		if err != nil {
+			return nil, err
+		}
+		entry = &agentEntry{
+			key:       key,
+			client:    client,
+			username:  username,
+			endpoints: GetTenantEndpoints(tenant),
+			audience:  req.Audience,
+			scope:     req.Scope,
+			params:    params,
+		}
+	}
+
+	token, err := a.fresh(entry, config.TokenExpiryLeeway)
+	if err != nil {
+		return nil, err
+	}
+
+	// entries are only held once they have a token
+	a.mu.Lock()
+	if a.entries[key] == nil {
+		a.entries[key] = entry
+	}
+	a.mu.Unlock()
+	return token, nil
+}
+
+// client returns the configuration of the client, loaded once so its secrets
+// stay in memory. The agent has to be restarted to see changed clients.
+func (a *Agent) client(clientName string) (*config.Client, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name := strings.ToLower(clientName)
+	if client, ok := a.clients[name]; ok {
+		return client, nil
+	}
+
+	clientConfig, err := config.LoadClientConfigWithViper()
+	if err != nil {
+		return nil, fmt.Errorf("could not load client config - %v", err)
+	}
+	client := clientConfig.GetClientConfig(name)
+	if client == nil {
+		return nil, fmt.Errorf("client %s does not exist", clientName)
+	}
+	// the agent can not ask for the vault passphrase, it has to be unlocked
+	client, err = config.ResolveClientSecrets(client)
+	if err != nil {
+		return nil, err
+	}
+	a.clients[name] = client
+	return client, nil
+}
+
+// fresh returns the entry's token, renewing it first when it expires within d
+func (a *Agent) fresh(entry *agentEntry, d time.Duration) (*TokenResponse, error) {
+	entry.renewing.Lock()
+	defer entry.renewing.Unlock()
+
+	a.mu.Lock()
+	token, refreshToken := entry.token, entry.refreshToken
+	a.mu.Unlock()
+	if token != nil && !expiresWithin(token, d) {
+		return token, nil
+	}
+
+	token, refreshToken, err := a.renew(entry, refreshToken)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry.refreshToken = refreshToken
+	if err != nil {
+		return nil, err
+	}
+	entry.token = token
+	return token, nil
+}
+
+// renew gets a new token for the entry without involving the user: client
+// credentials for machine to machine clients and the refresh token for the
+// others. It returns the refresh token to use next time.
+func (a *Agent) renew(entry *agentEntry, refreshToken string) (*TokenResponse, string, error) {
+	client := entry.client
+
+	var token *auth0TokenSuccessResponse
+	var err error
+	if client.ClientType == "Machine-to-Machine Application" {
+		token, err = requestClientToken(client, entry.endpoints, entry.audience, entry.scope, entry.params)
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		if refreshToken == "" {
+			refreshToken = storedRefreshToken(client, entry.username, entry.audience, entry.scope, entry.params.Encode())
+		}
+		if refreshToken == "" {
+			return nil, "", errAgentNeedsUser
+		}
+
+		token, err = exchangeRefreshToken(client, entry.endpoints, refreshToken)
+		if err == errRefreshTokenInvalid {
+			return nil, "", errAgentNeedsUser
+		}
+		if err != nil {
+			return nil, refreshToken, err
+		}
+
+		// with refresh token rotation the old one is gone, the store has to
+		// follow so commands run without the agent can still use it
+		if token.RefreshToken != "" {
+			refreshToken = token.RefreshToken
+			storeRefreshToken(client, entry.username, entry.audience, entry.scope, entry.params.Encode(), token)
+		}
+	}
+
+	cacheToken(entry.key, client, entry.username, entry.audience, entry.scope, entry.params.Encode(), token)
+	response := newTokenResponse(token)
+	response.RefreshToken = ""
+	return response, refreshToken, nil
+}
+
+// renewLoop renews held tokens before commands would have to wait for them
+func (a *Agent) renewLoop(done chan struct{}) {
+	ticker := time.NewTicker(agentRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		a.mu.Lock()
+		var expiring []*agentEntry
+		for _, entry := range a.entries {
+			if expiresWithin(entry.token, 2*config.TokenExpiryLeeway) {
+				expiring = append(expiring, entry)
+			}
+		}
+		a.mu.Unlock()
+
+		for _, entry := range expiring {
+			if _, err := a.fresh(entry, 2*config.TokenExpiryLeeway); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: dropping the token of client %s - %v\n",
+					time.Now().Format(time.RFC3339), entry.client.ClientName, err)
+				a.mu.Lock()
+				if a.entries[entry.key] == entry {
+					delete(a.entries, entry.key)
+				}
+				a.mu.Unlock()
+			}
+		}
+	}
+}
+
+// flush forgets the tokens and refresh tokens held for the tenant or client,
+// an empty name matches all. The clients are loaded again on their next use.
+func (a *Agent) flush(tenantName string, clientName string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	matches := func(client *config.Client) bool {
+		return (tenantName == "" || strings.EqualFold(client.TenantName, tenantName)) &&
+			(clientName == "" || strings.EqualFold(client.ClientName, clientName))
+	}
+
+	flushed := 0
+	for key, entry := range a.entries {
+		if matches(entry.client) {
+			delete(a.entries, key)
+			flushed++
+		}
+	}
+	for name, client := range a.clients {
+		if matches(client) {
+			delete(a.clients, name)
+		}
+	}
+	return flushed
+}
+
+func (a *Agent) status() *AgentStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := &AgentStatus{Pid: os.Getpid(), Started: a.started.Unix()}
+	if key, until := config.VaultKey(); key != nil {
+		status.VaultUnlocked, status.VaultUnlockedUntil = true, unixOrZero(until)
+	}
+	for _, entry := range a.entries {
+		status.Tokens = append(status.Tokens, AgentToken{
+			TenantName: entry.client.TenantName,
+			ClientName: entry.client.ClientName,
+			Username:   entry.username,
+			Audience:   entry.audience,
+			Scope:      entry.scope,
+			ExpiresAt:  entry.token.ExpiresAt,
+		})
+	}
+	sort.Slice(status.Tokens, func(i, j int) bool {
+		return status.Tokens[i].ClientName < status.Tokens[j].ClientName
+	})
+	return status
+}
+
+// unixOrZero returns t as a Unix time, keeping the zero time 0
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// expiresWithin reports whether the token expires within d
+func expiresWithin(token *TokenResponse, d time.Duration) bool {
+	if token.ExpiresAt == 0 {
+		return false
+	}
+	return time.Now().Add(d).After(time.Unix(token.ExpiresAt, 0))
+}
+
+// storedRefreshToken returns the refresh token stored for the client and test
+// user if it was issued for the audience, scope and login parameters
+func storedRefreshToken(client *config.Client, username string, audience string, scope string, params string) string {
+	store, err := config.LoadRefreshTokenStoreWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not load refresh tokens - %v\n", err)
+		return ""
+	}
+
+	stored := store.GetRefreshToken(client.TenantName, client.ClientName, audience, scope, params, username)
+	if stored == nil {
+		return ""
+	}
+	// the agent can not ask for the vault passphrase, it has to be unlocked
+	refreshToken, err := config.ResolveSecret(stored.RefreshToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read the refresh token - %v\n", err)
+		return ""
+	}
+	return refreshToken
+}
+
+// requestAgentToken asks a running agent for a token
+func requestAgentToken(client *config.Client, audience string, scope string, opts TokenOptions) (*TokenResponse, error) {
+	// the agent never runs the password flow
+	opts.Password = ""
+
+	res, err := callAgent(agentRequest{
+		Action:   agentActionToken,
+		Client:   client.ClientName,
+		Audience: audience,
+		Scope:    scope,
+		Opts:     opts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.NeedsUser {
+		return nil, errAgentNeedsUser
+	}
+	return res.Token, nil
+}
+
+// GetAgentStatus returns the status of the running agent
+func GetAgentStatus() (*AgentStatus, error) {
+	res, err := callAgent(agentRequest{Action: agentActionStatus})
+	if err != nil {
+		return nil, err
+	}
+	return res.Status, nil
+}
+
+// StopAgent stops the running agent and returns its last status
+func StopAgent() (*AgentStatus, error) {
+	res, err := callAgent(agentRequest{Action: agentActionStop})
+	if err != nil {
+		return nil, err
+	}
+	return res.Status, nil
+}
+
+// FlushAgent has the running agent forget the tokens it holds for the tenant
+// or client and returns how many it dropped
+func FlushAgent(tenantName string, clientName string) (int, error) {
+	res, err := callAgent(agentRequest{Action: agentActionFlush, Tenant: tenantName, Client: clientName})
+	if err != nil {
+		return 0, err
+	}
+	return res.Flushed, nil
+}
+
+// UnlockAgentVault hands the vault key to the running agent, which keeps it
+// in memory until the given time or, when it is zero, until it stops
+func UnlockAgentVault(key []byte, until time.Time) error {
+	_, err := callAgent(agentRequest{Action: agentActionUnlock, VaultKey: key, VaultUntil: unixOrZero(until)})
+	return err
+}
+
+// LockAgentVault has the running agent forget the vault key
+func LockAgentVault() error {
+	_, err := callAgent(agentRequest{Action: agentActionLock})
+	return err
+}
+
+// requestAgentSecret returns the secret in the vault the reference points to
+// from the running agent, when it holds the vault key
+func requestAgentSecret(ref string) (string, error) {
+	res, err := callAgent(agentRequest{Action: agentActionSecret, Secret: ref})
+	if err != nil {
+		return "", err
+	}
+	return res.Secret, nil
+}
+
+// requestAgentStoreSecret has the running agent store a secret in the vault
+// under key and returns the reference to it
+func requestAgentStoreSecret(key string, secret string) (string, error) {
+	res, err := callAgent(agentRequest{Action: agentActionStoreSecret, SecretKey: key, Secret: secret})
+	if err != nil {
+		return "", err
+	}
+	return res.Secret, nil
+}
+
+// requestAgentDeleteSecret has the running agent delete a secret from the
+// vault
+func requestAgentDeleteSecret(ref string) error {
+	_, err := callAgent(agentRequest{Action: agentActionDeleteSecret, Secret: ref})
+	return err
+}
+
+// IsAgentNotRunning reports whether err means no agent is running
+func IsAgentNotRunning(err error) bool {
+	return err == errAgentNotRunning
+}
+
+func callAgent(req agentRequest) (*agentResponse, error) {
+	socketPath, err := AgentSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil, errAgentNotRunning
+	}
+	defer conn.Close()
+	// renewing a token is a single request to auth0
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var res agentResponse
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		return nil, fmt.Errorf("could not read the agent's response - %v", err)
+	}
+	if res.Error != "" {
+		return nil, errors.New(res.Error)
+	}
+	return &res, nil
+}
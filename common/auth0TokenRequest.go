@@ -1,26 +1,34 @@
 package common
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/bluce-clj/spsauth0/internal/config"
 	cv "github.com/nirasan/go-oauth-pkce-code-verifier"
 	"io"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"github.com/skratchdot/open-golang/open"
 )
 
 type auth0TokenRequest struct {
-	GrantType    string `json:"grant_type"`
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
-	Audience     string `json:"audience"`
+	GrantType           string `json:"grant_type"`
+	ClientID            string `json:"client_id"`
+	ClientSecret        string `json:"client_secret,omitempty"`
+	ClientAssertion     string `json:"client_assertion,omitempty"`
+	ClientAssertionType string `json:"client_assertion_type,omitempty"`
+	Audience            string `json:"audience"`
+	Scope               string `json:"scope,omitempty"`
 }
 
 const (
@@ -29,157 +37,652 @@ const (
 
 type auth0TokenErrorResponse struct {
 	Error            string `json:"error"`
-	ErrorDescription string `json:"errorDescription"`
+	ErrorDescription string `json:"error_description"`
 }
 
 type auth0TokenSuccessResponse struct {
-	AccessToken string `json:"access_token"`
-	Scope       string `json:"scope"`
-	ExpiresIn   int    `json:"expires_in"`
-	TokenType   string `json:"token_type"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
 }
 
-var accessToken = ""
+// TokenOptions controls how GetTokenHandler obtains a token
+type TokenOptions struct {
+	// NoCache skips the token cache and always requests a new token from auth0
+	NoCache bool
+	// Refresh skips the token cache and exchanges the stored refresh token for
+	// a new access token
+	Refresh bool
+	// Flow overrides the flow configured on the client for user tokens
+	Flow string
+	// Username and Password override the test user of the password flow
+	Username string
+	Password string
+	// RedirectURI and RedirectPorts override the callback the browser flow
+	// listens on
+	RedirectURI   string
+	RedirectPorts []int
+	// NoBrowser prints the authorization URL instead of opening a browser and
+	// accepts the redirect URL pasted back into the terminal
+	NoBrowser bool
+	// Audience overrides the audience configured on the client and tenant
+	Audience string
+	// Scope is the space separated API permissions to request, when empty
+	// they are picked from the permissions of the audience's API
+	Scope string
+	// NoPrompt fails instead of asking for the audience, the test user or a
+	// login and requests the scope as given, for commands whose terminal
+	// belongs to another tool
+	NoPrompt bool
+	// Organization, Connection, Prompt, ScreenHint, LoginHint, MaxAge and
+	// ExtraParams override the login parameters configured on the client
+	Organization string
+	Connection   string
+	Prompt       string
+	ScreenHint   string
+	LoginHint    string
+	MaxAge       string
+	ExtraParams  []string
+}
+
+// TokenResponse is a token for a client along with everything auth0 returned
+// with it. Tokens served from the cache carry no refresh token, it is kept in
+// the refresh token store.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	IDToken      string `json:"id_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+	ExpiresAt    int64  `json:"expires_at,omitempty"`
+}
+
+func GetTokenHandler(client *config.Client, opts TokenOptions) string {
+	return GetToken(client, opts).AccessToken
+}
+
+// GetToken returns a token for the client, from the token cache when there is
+// a valid one and from auth0 otherwise.
+func GetToken(client *config.Client, opts TokenOptions) *TokenResponse {
+	tenantConfig, tenant := loadClientTenant(client)
+	endpoints := GetTenantEndpoints(tenant)
 
-func GetTokenHandler(client *config.Client) string {
+	audience := getAudience(client, tenant, tenantConfig, opts)
+	scope := getScope(client, tenant, audience, opts)
+	return getTokenFor(client, endpoints, audience, scope, opts)
+}
+
+// GetTokenWithAgent returns a token for the client from the agent when it is
+// running, and gets it like GetToken otherwise. The audience and scope are
+// settled first, prompting when needed, since the agent has no terminal.
+func GetTokenWithAgent(client *config.Client, opts TokenOptions) *TokenResponse {
+	if opts.NoCache || opts.Refresh {
+		return GetToken(client, opts)
+	}
 
+	tenantConfig, tenant := loadClientTenant(client)
+	audience := getAudience(client, tenant, tenantConfig, opts)
+	scope := getScope(client, tenant, audience, opts)
+	getLoginParams(client, opts)
+	// the agent can not ask for the test user
+	opts.Username = getTestUsername(client, opts)
+
+	token, err := requestAgentToken(client, audience, scope, opts)
+	if err == nil {
+		return token
+	}
+	if err != errAgentNotRunning && err != errAgentNeedsUser {
+		fmt.Fprintf(os.Stderr, "Warning: the agent could not provide a token - %v\n", err)
+	}
+	return getTokenFor(client, GetTenantEndpoints(tenant), audience, scope, opts)
+}
+
+// getTokenFor returns a token for the client once the audience and scope are
+// known
+func getTokenFor(client *config.Client, endpoints *config.OIDCConfiguration, audience string, scope string, opts TokenOptions) *TokenResponse {
+	params := getLoginParams(client, opts)
+	username := getTestUsername(client, opts)
+
+	key := config.TokenCacheKey(client.TenantName, client.ClientName, audience, scope, params.Encode(), username)
+	if !opts.NoCache && !opts.Refresh {
+		if token := getCachedToken(key); token != nil {
+			return cachedTokenResponse(token)
+		}
+	}
+
+	var token *auth0TokenSuccessResponse
 	switch client.ClientType {
 	case "Machine-to-Machine Application":
-		return getClientToken(client)
+		token = getClientToken(client, endpoints, audience, scope, params)
 	default:
-		return getUserTokenPKCE(client)
+		// only go through the browser when there is no usable refresh token
+		if supportsRefreshToken(client) {
+			token = refreshAccessToken(client, endpoints, username, audience, scope, params.Encode())
+		}
+		if token == nil {
+			switch getFlow(client, opts) {
+			case config.FlowDevice:
+				token = getUserTokenDevice(client, endpoints, audience, scope, params, opts)
+			case config.FlowPassword:
+				token = getUserTokenPassword(client, endpoints, username, audience, scope, params, opts)
+			default:
+				token = getUserTokenPKCE(client, endpoints, audience, scope, params, opts)
+			}
+		}
+		storeRefreshToken(client, username, audience, scope, params.Encode(), token)
 	}
+
+	reportGrantedScope(scope, token.Scope)
+	cacheToken(key, client, username, audience, scope, params.Encode(), token)
+	return newTokenResponse(token)
 }
 
-// GetClientToken used for client_credential flow
-func getClientToken(client *config.Client)  string{
+// newTokenResponse returns the token auth0 just issued, with its expiry
+func newTokenResponse(token *auth0TokenSuccessResponse) *TokenResponse {
+	response := &TokenResponse{
+		AccessToken:  token.AccessToken,
+		IDToken:      token.IDToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+		Scope:        token.Scope,
+		ExpiresIn:    token.ExpiresIn,
+	}
+	if token.ExpiresIn > 0 {
+		response.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second).Unix()
+	}
+	return response
+}
 
-	tenantConfig, err := config.LoadTenantConfigWithViper()
+// exitIfNoPrompt stops commands that may not involve the user, such as the
+// credential helpers, with the error saying what they would have needed
+func exitIfNoPrompt(opts TokenOptions, format string, args ...interface{}) {
+	if !opts.NoPrompt {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// loadClientTenant loads the tenant config and the tenant the client belongs to
+func loadClientTenant(client *config.Client) (*config.TenantConfig, *config.Tenant) {
+	tenantConfig, tenant, err := findClientTenant(client)
 	if err != nil {
-		fmt.Printf("Error: could not load tenant config - %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	return tenantConfig, tenant
+}
+
+// findClientTenant is loadClientTenant for callers that have to keep running
+func findClientTenant(client *config.Client) (*config.TenantConfig, *config.Tenant, error) {
+	tenantConfig, err := config.LoadTenantConfigWithViper()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load tenant config - %v", err)
+	}
 
 	tenant := tenantConfig.GetTenantConfig(strings.ToLower(client.TenantName))
 	if tenant == nil {
-		fmt.Println("Something went wrong in getting the tenant information for the client.")
+		return nil, nil, fmt.Errorf("tenant %s of client %s is not configured", client.TenantName, client.ClientName)
+	}
+	return tenantConfig, tenant, nil
+}
+
+// getFlow returns the flow used to get a user token for the client: the one
+// asked for on the command line, then the one configured on the client.
+func getFlow(client *config.Client, opts TokenOptions) string {
+	flow := opts.Flow
+	if flow == "" {
+		flow = client.Flow
+	}
+	if flow == "" {
+		return config.FlowBrowser
+	}
+
+	for _, supported := range config.GetSupportedFlows() {
+		if flow == supported {
+			return flow
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unsupported flow %s, use one of %v\n", flow, config.GetSupportedFlows())
+	os.Exit(1)
+	return ""
+}
+
+// getAudience returns the audience the token for this client is requested
+// for: the one asked for on the command line, then the client's default
+// audience, then the tenant's default API. Without any of them the user picks
+// one of the tenant's APIs.
+func getAudience(client *config.Client, tenant *config.Tenant, tenantConfig *config.TenantConfig, opts TokenOptions) string {
+	audience := firstNonEmpty(opts.Audience, client.Audience, tenant.Tenant.DefaultAudience)
+	if audience != "" {
+		return audience
+	}
+	if opts.NoPrompt {
+		fmt.Fprintf(os.Stderr, "Client %s has no default audience, give one with --audience\n", client.ClientName)
 		os.Exit(1)
 	}
+	return getAudienceFromTenant(tenant, tenantConfig)
+}
+
+// getRequestedScope returns the scope the flow for this client type asks for.
+// User logins ask for an ID token and access to /userinfo along with the
+// access token.
+func getRequestedScope(client *config.Client) string {
+	switch client.ClientType {
+	case "Machine-to-Machine Application":
+		return ""
+	case "Native Application", "Web Service Application":
+		return "openid profile email offline_access"
+	}
+	return "openid profile email"
+}
+
+// getScope returns the scope to request: the scopes of the client's flow plus
+// the API permissions given on the command line or, when there are none, the
+// ones of a token already cached or refreshable for the audience. Without such
+// a token they are picked from the permissions defined on the audience's API
+// unless prompting is off.
+func getScope(client *config.Client, tenant *config.Tenant, audience string, opts TokenOptions) string {
+	scopes := strings.Fields(getRequestedScope(client))
+
+	permissions := strings.Fields(opts.Scope)
+	if len(permissions) == 0 && !opts.NoPrompt {
+		if api := tenant.GetAPIByAudience(audience); api != nil && len(api.Permissions) > 0 {
+			params := getLoginParams(client, opts)
+			if used := usedScope(client, audience, params.Encode(), testUsername(client, opts)); used != "" {
+				return joinScopes(append(scopes, strings.Fields(used)...))
+			}
+
+			var err error
+			permissions, err = PromptMultiSelect("Select the permissions to request", api.Permissions)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+	return joinScopes(append(scopes, permissions...))
+}
+
+// joinScopes returns the scopes space separated, leaving out repeats
+func joinScopes(scopes []string) string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return strings.Join(unique, " ")
+}
+
+// reportGrantedScope tells the user when auth0 granted a different scope than
+// the one requested, e.g. because the client is not authorized for some of the
+// API's permissions.
+func reportGrantedScope(requested string, granted string) {
+	if requested == "" || granted == "" || sameScope(requested, granted) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Requested scopes: %s\nGranted scopes:   %s\n", requested, granted)
+}
+
+// sameScope reports whether two space separated scopes hold the same scopes
+func sameScope(a string, b string) bool {
+	aScopes := strings.Fields(a)
+	bScopes := strings.Fields(b)
+	sort.Strings(aScopes)
+	sort.Strings(bScopes)
+	return strings.Join(aScopes, " ") == strings.Join(bScopes, " ")
+}
 
-	audience := getAudienceFromTenant(tenant, tenantConfig)
+// GetClientToken used for client_credential flow
+func getClientToken(client *config.Client, endpoints *config.OIDCConfiguration, audience string, scope string, params url.Values) *auth0TokenSuccessResponse {
+	warnUnsupportedGrant(endpoints, "client_credentials")
+
+	token, err := requestClientToken(client, endpoints, audience, scope, params)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	return token
+}
 
-	jsonBody, _ := json.Marshal(auth0TokenRequest{
+// requestClientToken makes the client credentials request, returning the
+// error instead of exiting so the agent can keep running
+func requestClientToken(client *config.Client, endpoints *config.OIDCConfiguration, audience string, scope string, params url.Values) (*auth0TokenSuccessResponse, error) {
+
+	tokenRequest := auth0TokenRequest{
 		GrantType:    "client_credentials",
 		ClientID:     client.ClientId,
 		ClientSecret: client.ClientSecret,
 		Audience:     audience,
-	})
+		Scope:        scope,
+	}
+	if usesPrivateKeyJWT(client) {
+		assertion, err := createClientAssertion(client, endpoints.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		tokenRequest.ClientSecret = ""
+		tokenRequest.ClientAssertion = assertion
+		tokenRequest.ClientAssertionType = clientAssertionType
+	} else {
+		secret, err := resolveSecret(client, client.ClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		tokenRequest.ClientSecret = secret
+	}
+	jsonBody, _ := json.Marshal(tokenRequest)
+	if tokenParams := getTokenParams(params); len(tokenParams) > 0 {
+		body := make(map[string]interface{})
+		json.Unmarshal(jsonBody, &body)
+		for name := range tokenParams {
+			body[name] = tokenParams.Get(name)
+		}
+		jsonBody, _ = json.Marshal(body)
+	}
 
-	url := "https://" + tenant.Tenant.Domain + OAuthTokenPattern
-	res, err := http.Post(url, "application/json", bytes.NewBuffer(jsonBody))
+	tokenURL, err := checkEndpoint("token", endpoints.TokenEndpoint)
 	if err != nil {
-		fmt.Printf("Error executing http request: %v", err)
-		os.Exit(1)
+		return nil, err
 	}
+	res, err := httpClient.Post(tokenURL, "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("error executing http request: %v", err)
+	}
+	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
 		var body auth0TokenErrorResponse
 		json.NewDecoder(res.Body).Decode(&body)
-		defer res.Body.Close()
-		fmt.Printf("Call to obtain auth0 token returned non-OK status %d: %v\n", res.StatusCode, body)
-		os.Exit(1)
+		return nil, fmt.Errorf("call to obtain auth0 token returned non-OK status %d: %v", res.StatusCode, body)
 	}
 
 	var body auth0TokenSuccessResponse
-	err = json.NewDecoder(res.Body).Decode(&body)
-	defer res.Body.Close()
-
-	return body.AccessToken
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("could not read the token response - %v", err)
+	}
+	return &body, nil
 }
 
 func getAudienceFromTenant(tenant *config.Tenant, tenantConfig *config.TenantConfig) string {
 	if len(tenant.Tenant.APIs) == 0 {
 		// add Use command to output
-		fmt.Printf("To request a token for this client you need to add configures API to the %s tenant. Use ``")
+		fmt.Fprintf(os.Stderr, "To request a token for this client you need to add configures API to the %s tenant. Use ``", tenant.Tenant.Name)
 		os.Exit(1)
 	}
 
 	i, _, err := PromptSelect("Select the audience that this token is for", tenantConfig.GetTenantAPINames(tenant.Tenant.APIs))
 	if err != nil {
-		fmt.Printf(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 	return tenant.Tenant.APIs[i].Audience
 }
 
 // AuthorizeUser implements the PKCE OAuth2 flow.
-func getUserTokenPKCE(client *config.Client)  string{
+func getUserTokenPKCE(client *config.Client, endpoints *config.OIDCConfiguration, audience string, scope string, params url.Values, opts TokenOptions) *auth0TokenSuccessResponse {
+	exitIfNoPrompt(opts, "client %s needs a browser login, run 'spsauth0 client token' for it first so its refresh token can be used", client.ClientName)
 
-	tenantConfig, err := config.LoadTenantConfigWithViper()
-	if err != nil {
-		fmt.Printf("Error: could not load tenant config - %v\n", err)
-		os.Exit(1)
-	}
-
-	tenant := tenantConfig.GetTenantConfig(strings.ToLower(client.TenantName))
-	if tenant == nil {
-		fmt.Println("Something went wrong in getting the tenant information for the client.")
-		os.Exit(1)
-	}
-	
 	additionalQueryParams := ""
 	codeVerifier := ""
 	switch client.ClientType {
 	case "Native Application":
-			additionalQueryParams,  codeVerifier = pkceAuthorizationQueryParams()
+		additionalQueryParams, codeVerifier = pkceAuthorizationQueryParams()
+		warnUnsupportedGrant(endpoints, "authorization_code")
+		if !endpoints.SupportsCodeChallengeMethod("S256") {
+			fmt.Fprintln(os.Stderr, "Warning: the tenant does not advertise support for the S256 PKCE method")
+		}
 	case "Web Service Application":
-			additionalQueryParams = webServiceAppAuthorizationQueryParams()
+		additionalQueryParams = webServiceAppAuthorizationQueryParams()
+		warnUnsupportedGrant(endpoints, "authorization_code")
 	case "Single-Page Application (SPA)":
-			additionalQueryParams = spaAuthorizationQueryParams()
+		additionalQueryParams = spaAuthorizationQueryParams()
+		warnUnsupportedGrant(endpoints, "implicit")
+	}
+
+	// set up a listener on the first redirect port that is free, the redirect
+	// URL sent to auth0 has to name the port that was actually bound
+	l, callbackURL := listenForCallback(client, opts)
+	redirectURL := callbackURL.String()
+
+	// the state ties the callback to this login so codes injected by anything
+	// else that can reach the callback server are rejected. The nonce does the
+	// same for the ID token and is only sent when one is requested
+	state := newRandomString()
+	nonce := ""
+	if hasScope(scope, "openid") {
+		nonce = newRandomString()
+		additionalQueryParams += "&nonce=" + nonce
+	}
+	if scope != "" {
+		additionalQueryParams += "&scope=" + url.QueryEscape(scope)
+	}
+	if len(params) > 0 {
+		additionalQueryParams += "&" + params.Encode()
 	}
 
 	// construct the authorization URL (with Auth0 as the authorization provider)
-	redirectURL :=  "http://localhost:1000"
 	authorizationURL := fmt.Sprintf(
-		"https://" + tenant.Tenant.Domain + "/authorize" +
-			"?audience=api://api.spscommerce.com/"+
+		"%s?audience=%s"+
 			"&client_id=%s"+
 			"&redirect_uri=%s"+
-			additionalQueryParams,
-		client.ClientId, redirectURL)
+			"&state=%s%s",
+		requireEndpoint("authorization", endpoints.AuthorizationEndpoint), url.QueryEscape(audience),
+		client.ClientId, url.QueryEscape(redirectURL), state, additionalQueryParams)
+
+	// completeLogin checks the parameters the login redirected back with and
+	// trades the authorization code for a token. SPA logins return the token
+	// itself in the URL fragment, which the callback page posts back
+	completeLogin := func(callback url.Values) (*auth0TokenSuccessResponse, error) {
+		if callback.Get("state") != state {
+			return nil, errStateMismatch
+		}
+		if callback.Get("error") != "" {
+			fmt.Fprintf(os.Stderr, "login failed: %s %s\n", callback.Get("error"), callback.Get("error_description"))
+			return nil, fmt.Errorf("login failed: %s", callback.Get("error"))
+		}
+
+		var tokenResponse *auth0TokenSuccessResponse
+		var err error
+		if client.ClientType == "Single-Page Application (SPA)" {
+			tokenResponse, err = implicitTokenResponse(callback)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			// get the authorization code
+			code := callback.Get("code")
+			if code == "" {
+				fmt.Fprintln(os.Stderr, "Url Param 'code' is missing")
+				return nil, errors.New("could not find 'code' URL parameter")
+			}
+
+			tokenResponse, err = getAccessToken(client, codeVerifier, code, redirectURL, endpoints, getTokenParams(params))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "could not get access token")
+				return nil, errors.New("could not retrieve access token")
+			}
+		}
+
+		if nonce != "" && (tokenResponse.IDToken != "" || client.ClientType != "Single-Page Application (SPA)") {
+			if err := validateNonce(tokenResponse.IDToken, nonce); err != nil {
+				fmt.Fprintf(os.Stderr, "could not validate ID token: %v\n", err)
+				return nil, errors.New("ID token nonce does not match the login started by spsauth0")
+			}
+		}
+		return tokenResponse, nil
+	}
+
+	// the token comes back from either the callback server or a pasted URL,
+	// whichever is first
+	results := make(chan loginResult, 2)
 
 	// start a web server to listen on a callback URL
-	server := &http.Server{Addr: redirectURL}
+	mux := http.NewServeMux()
+	server := &http.Server{Handler: mux}
 
-	// define a handler that will get the authorization code, call the token endpoint, and close the HTTP server
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	// define a handler that will get the authorization code and call the token endpoint
+	mux.HandleFunc(callbackPath(callbackURL), func(w http.ResponseWriter, r *http.Request) {
 
 		if client.ClientType != "Single-Page Application (SPA)" {
-			// get the authorization code
-			code := r.URL.Query().Get("code")
-			if code == "" {
-				fmt.Println("Url Param 'code' is missing")
-				io.WriteString(w, "Error: could not find 'code' URL parameter\n")
-
-				// close the HTTP server and return
-				cleanup(server)
+			tokenResponse, err := completeLogin(r.URL.Query())
+
+			// reject callbacks that do not belong to the login started above,
+			// the server keeps waiting for the real one
+			if err == errStateMismatch {
+				fmt.Fprintln(os.Stderr, "Ignoring a callback whose state does not match the login")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, "Error: state does not match the login started by spsauth0\n")
 				return
 			}
 
-			accessToken, err = getAccessToken(client, codeVerifier, code, redirectURL, tenant.Tenant.Domain)
-
 			if err != nil {
-				fmt.Println("could not get access token")
-				io.WriteString(w, "Error: could not retrieve access token\n")
-
-				// close the HTTP server and return
-				cleanup(server)
+				io.WriteString(w, fmt.Sprintf("Error: %v\n", err))
+				results <- loginResult{err: err}
 				return
 			}
+
+			// return an indication of success to the caller
+			io.WriteString(w, callbackPage)
+			results <- loginResult{token: tokenResponse}
+			return
+		}
+
+		// SPA logins are only done once the page has posted the fragment back
+		io.WriteString(w, callbackPage)
+	})
+
+	// define a handler for the fragment the callback page posts back, the
+	// browser never sends it to the server on its own
+	mux.HandleFunc(implicitCallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || client.ClientType != "Single-Page Application (SPA)" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
 		}
+		r.ParseForm()
+
+		tokenResponse, err := completeLogin(r.PostForm)
+		if err == errStateMismatch {
+			fmt.Fprintln(os.Stderr, "Ignoring a callback whose state does not match the login")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "Error: state does not match the login started by spsauth0\n")
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, fmt.Sprintf("Error: %v\n", err))
+			results <- loginResult{err: err}
+			return
+		}
+
+		io.WriteString(w, "The token was handed to spsauth0.\n")
+		results <- loginResult{token: tokenResponse}
+	})
 
-		// return an indication of success to the caller
-		io.WriteString(w, `
+	// start the web server loop in the background, it is shut down once a
+	// token has been received
+	go server.Serve(l)
+
+	// open a browser window to the authorizationURL, or have the user open it
+	// wherever their browser is and paste the redirect back
+	browserOpened := false
+	if !opts.NoBrowser {
+		err := open.Start(authorizationURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "can't open browser to URL %s: %s\n", authorizationURL, err)
+		} else {
+			browserOpened = true
+		}
+	}
+	if !browserOpened {
+		fmt.Fprintf(os.Stderr, "\nOpen this URL in a browser to log in:\n\n%s\n\n", authorizationURL)
+		fmt.Fprintln(os.Stderr, "If the browser runs on another machine, paste the URL it was redirected to"+
+			" here once the login is done (the page itself may fail to load):")
+		go readPastedCallback(completeLogin, results)
+	}
+
+	result := <-results
+	server.Shutdown(context.Background())
+	if result.err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", result.err)
+		os.Exit(1)
+	}
+	return result.token
+}
+
+// loginResult is what a browser login ended with, a token or the reason
+// there is none
+type loginResult struct {
+	token *auth0TokenSuccessResponse
+	err   error
+}
+
+// implicitCallbackPath is where the callback page posts the URL fragment of
+// an SPA login
+const implicitCallbackPath = "/spsauth0/implicit"
+
+// errStateMismatch means a callback does not belong to the login in progress
+var errStateMismatch = errors.New("state does not match the login started by spsauth0")
+
+// readPastedCallback reads redirect URLs pasted into the terminal until one
+// completes the login
+func readPastedCallback(completeLogin func(url.Values) (*auth0TokenSuccessResponse, error), results chan<- loginResult) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		u, err := url.Parse(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "That is not a URL (%v), paste the full URL from the browser's address bar:\n", err)
+			continue
+		}
+
+		// codes come back in the query, SPA tokens in the fragment
+		params := u.Query()
+		fragment, _ := url.ParseQuery(u.Fragment)
+		for k, v := range fragment {
+			params[k] = v
+		}
+
+		tokenResponse, err := completeLogin(params)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v, paste the URL again:\n", err)
+			continue
+		}
+		results <- loginResult{token: tokenResponse}
+		return
+	}
+}
+
+// implicitTokenResponse reads the token an implicit flow login returned
+func implicitTokenResponse(params url.Values) (*auth0TokenSuccessResponse, error) {
+	if params.Get("access_token") == "" {
+		return nil, errors.New("could not find 'access_token' URL parameter")
+	}
+
+	expiresIn, _ := strconv.Atoi(params.Get("expires_in"))
+	return &auth0TokenSuccessResponse{
+		AccessToken: params.Get("access_token"),
+		IDToken:     params.Get("id_token"),
+		Scope:       params.Get("scope"),
+		ExpiresIn:   expiresIn,
+		TokenType:   params.Get("token_type"),
+	}, nil
+}
+
+// callbackPage is shown in the browser once the login redirected back
+const callbackPage = `
 		<html>
 			<body>
 				<pre>
@@ -197,90 +700,71 @@ func getUserTokenPKCE(client *config.Client)  string{
 
 				<div> 
 				<script>
-    					document.getElementById("authToken").innerHTML = 
-						window.location.hash;
+					// SPA logins return the token in the fragment, hand it to spsauth0
+					if (window.location.hash.length > 1) {
+						fetch("` + implicitCallbackPath + `", {
+							method: "POST",
+							headers: {"Content-Type": "application/x-www-form-urlencoded"},
+							body: window.location.hash.substring(1)
+						}).then(function (res) {
+							return res.text();
+						}).then(function (text) {
+							document.getElementById("authToken").textContent = text;
+						});
+					}
 				</script>
 				</div>
 			</body>
-		</html>`)
-
-		// close the HTTP server
-		cleanup(server)
-	})
-
-	// parse the redirect URL for the port number
-	u, err := url.Parse(redirectURL)
-	if err != nil {
-		fmt.Printf("bad redirect URL: %s\n", err)
-		os.Exit(1)
-	}
-
-	// set up a listener on the redirect port
-	port := fmt.Sprintf(":%s", u.Port())
-	l, err := net.Listen("tcp", port)
-	if err != nil {
-		fmt.Printf("can't listen to port %s: %s\n", port, err)
-		os.Exit(1)
-	}
-
-	// open a browser window to the authorizationURL
-	err = open.Start(authorizationURL)
-	if err != nil {
-		fmt.Printf("can't open browser to URL %s: %s\n", authorizationURL, err)
-		os.Exit(1)
-	}
-
-	// start the blocking web server loop
-	// this will exit when the handler gets fired and calls server.Close()
-	server.Serve(l)
-	return accessToken
-}
+		</html>`
 
 // getAccessToken trades the authorization code retrieved from the first OAuth2 leg for an access token
-func getAccessToken(client *config.Client, codeVerifier string, authorizationCode string, callbackURL string, domain string) (string, error) {
+func getAccessToken(client *config.Client, codeVerifier string, authorizationCode string, callbackURL string, endpoints *config.OIDCConfiguration, tokenParams url.Values) (*auth0TokenSuccessResponse, error) {
 	// set the url and form-encoded data for the POST to the access token endpoint
-	url := "https://" + domain + "/oauth/token"
+	tokenURL := requireEndpoint("token", endpoints.TokenEndpoint)
 	
 	additionalQueryParams := ""
 	switch  client.ClientType{
 	case "Native Application":
 		additionalQueryParams = pkceAccessTokenQueryParams(codeVerifier)
 	case "Web Service Application":
-		additionalQueryParams = webServiceAppTokenQueryParams(client.ClientSecret)
+		additionalQueryParams = webServiceAppTokenQueryParams(client, endpoints)
+	}
+	if len(tokenParams) > 0 {
+		additionalQueryParams += "&" + tokenParams.Encode()
 	}
 
 	data := fmt.Sprintf(
 		"grant_type=authorization_code&client_id=%s"+
 			"&code=%s"+
-			"&redirect_uri=%s"+
-			additionalQueryParams,
-		client.ClientId, authorizationCode, callbackURL)
+			"&redirect_uri=%s%s",
+		client.ClientId, authorizationCode, url.QueryEscape(callbackURL), additionalQueryParams)
 	payload := strings.NewReader(data)
 
 	// create the request and execute it
-	req, _ := http.NewRequest("POST", url, payload)
+	req, _ := http.NewRequest("POST", tokenURL, payload)
 	req.Header.Add("content-type", "application/x-www-form-urlencoded")
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
-		fmt.Printf("snap: HTTP error: %s", err)
-		return "", err
+		fmt.Fprintf(os.Stderr, "snap: HTTP error: %s", err)
+		return nil, err
 	}
 
 	// process the response
 	defer res.Body.Close()
-	var responseData map[string]interface{}
+	var responseData auth0TokenSuccessResponse
 	body, _ := ioutil.ReadAll(res.Body)
 
-	// unmarshal the json into a string map
+	// unmarshal the json into the token response
 	err = json.Unmarshal(body, &responseData)
 	if err != nil {
-		fmt.Printf("JSON error: %s", err)
-		return "", err
+		fmt.Fprintf(os.Stderr, "JSON error: %s", err)
+		return nil, err
 	}
 
-	// retrieve the access token out of the map, and return to caller
-	accessToken := responseData["access_token"].(string)
-	return accessToken, nil
+	if responseData.AccessToken == "" {
+		return nil, fmt.Errorf("no access token in response: %s", body)
+	}
+	return &responseData, nil
 }
 
 func pkceAuthorizationQueryParams() (string, string) {
@@ -290,7 +774,7 @@ func pkceAuthorizationQueryParams() (string, string) {
 	// Create code_challenge with S256 method
 	codeChallenge := CodeVerifier.CodeChallengeS256()
 	
-	return fmt.Sprintf("&code_challenge=%s&code_challenge_method=S256&scope=offline_access&response_type=code",
+	return fmt.Sprintf("&code_challenge=%s&code_challenge_method=S256&response_type=code",
 		codeChallenge), CodeVerifier.String()
 }
 
@@ -299,20 +783,18 @@ func pkceAccessTokenQueryParams(codeVerifier string) string{
 }
 
 func webServiceAppAuthorizationQueryParams() string {
-	return fmt.Sprintf("&scope=offline_access&response_type=code")
+	return "&response_type=code"
 }
 
-func webServiceAppTokenQueryParams(clientSecret string)  string{
-	return fmt.Sprintf("&client_secret=%s", clientSecret)
+func webServiceAppTokenQueryParams(client *config.Client, endpoints *config.OIDCConfiguration) string {
+	data := url.Values{}
+	setClientAuthentication(data, client, endpoints)
+	if len(data) == 0 {
+		return ""
+	}
+	return "&" + data.Encode()
 }
 
 func spaAuthorizationQueryParams() string{
-	return "&response_type=token"
+	return "&response_type=" + url.QueryEscape("token id_token")
 }
-
-// cleanup closes the HTTP server
-func cleanup(server *http.Server) {
-	// we run this as a goroutine so that this function falls through and
-	// the socket to the browser gets flushed/closed before the server goes away
-	go server.Close()
-}
\ No newline at end of file
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// newRandomString returns a random, URL safe value for state and nonce parameters
+func newRandomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not generate random value - %v\n", err)
+		os.Exit(1)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hasScope reports whether the space separated scope contains name
+func hasScope(scope string, name string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNonce checks the ID token carries the nonce sent to /authorize
+func validateNonce(idToken string, nonce string) error {
+	if idToken == "" {
+		return errors.New("no ID token was returned")
+	}
+
+	jwt, err := DecodeJWT(idToken)
+	if err != nil {
+		return err
+	}
+	if jwt.StringClaim("nonce") != nonce {
+		return errors.New("nonce does not match")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// BulkTokenResult is how fetching a token for one client went
+type BulkTokenResult struct {
+	Client       *config.Client
+	Audience     string
+	GrantedScope string
+	Latency      time.Duration
+	Err          error
+}
+
+// GetClientTokens fetches a client credentials token for each of the machine
+// to machine clients, at most workers at a time, and reports how each went in
+// the order of the clients. Tokens are always requested from auth0, the point
+// is to find out whether the credentials still work. Nothing is prompted for:
+// the audience comes from opts, the client or its tenant and the scope from
+// opts.
+func GetClientTokens(clients []*config.Client, opts TokenOptions, workers int) []*BulkTokenResult {
+	type job struct {
+		result    *BulkTokenResult
+		endpoints *config.OIDCConfiguration
+		scope     string
+		params    url.Values
+	}
+
+	// the configs and discovery documents are read up front, the workers only
+	// talk to auth0. A client that is not set up right fails on its own
+	results := make([]*BulkTokenResult, 0, len(clients))
+	jobs := make([]*job, 0, len(clients))
+	for _, client := range clients {
+		if client.ClientType != "Machine-to-Machine Application" {
+			continue
+		}
+		result := &BulkTokenResult{Client: client}
+		results = append(results, result)
+
+		_, tenant, err := findClientTenant(client)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		result.Audience = firstNonEmpty(opts.Audience, client.Audience, tenant.Tenant.DefaultAudience)
+		if result.Audience == "" {
+			result.Err = errors.New("no audience, set one on the client or the tenant or pass --audience")
+			continue
+		}
+		params, err := parseLoginParams(client, opts)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		endpoints := GetTenantEndpoints(tenant)
+		warnUnsupportedGrant(endpoints, "client_credentials")
+		jobs = append(jobs, &job{
+			result:    result,
+			endpoints: endpoints,
+			scope:     joinScopes(strings.Fields(opts.Scope)),
+			params:    params,
+		})
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	queue := make(chan *job)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				start := time.Now()
+				token, err := requestClientToken(j.result.Client, j.endpoints, j.result.Audience, j.scope, j.params)
+				j.result.Latency = time.Since(start)
+				if err != nil {
+					j.result.Err = err
+					continue
+				}
+				j.result.GrantedScope = token.Scope
+			}
+		}()
+	}
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// DefaultRedirectURI is the callback the browser flow listens on when neither
+// the client nor the command line configure one
+const DefaultRedirectURI = "http://localhost:1000"
+
+// listenForCallback binds the local callback server. The redirect URI comes
+// from the command line, then the client, then DefaultRedirectURI. When
+// redirect ports are configured they are tried in order in place of the
+// URI's own port. It returns the listener and the URI that was bound.
+func listenForCallback(client *config.Client, opts TokenOptions) (net.Listener, *url.URL) {
+	redirectURI := firstNonEmpty(opts.RedirectURI, client.RedirectURI, DefaultRedirectURI)
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Hostname() == "" {
+		fmt.Fprintf(os.Stderr, "bad redirect URL %s: %v\n", redirectURI, err)
+		os.Exit(1)
+	}
+
+	ports := opts.RedirectPorts
+	if len(ports) == 0 {
+		ports = client.RedirectPorts
+	}
+	if len(ports) == 0 {
+		port := u.Port()
+		if port == "" {
+			port = "80"
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bad redirect URL port %s: %v\n", port, err)
+			os.Exit(1)
+		}
+		ports = []int{p}
+	}
+
+	for _, port := range ports {
+		l, err := net.Listen("tcp", net.JoinHostPort(u.Hostname(), strconv.Itoa(port)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "can't listen to port %d: %s\n", port, err)
+			continue
+		}
+
+		bound := *u
+		bound.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(port))
+		return l, &bound
+	}
+
+	fmt.Fprintf(os.Stderr, "can't listen to any of the redirect ports %v\n", ports)
+	os.Exit(1)
+	return nil, nil
+}
+
+// callbackPath returns the path the callback handler is registered on
+func callbackPath(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	clientAssertionType     = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	clientAssertionLifetime = 60 * time.Second
+)
+
+// usesPrivateKeyJWT reports whether the client authenticates with a signed
+// client assertion instead of its secret
+func usesPrivateKeyJWT(client *config.Client) bool {
+	return client.PrivateKeyPath != ""
+}
+
+// setClientAuthentication adds the client's credentials to a form encoded
+// token request: a signed client assertion for Private Key JWT clients, the
+// client secret otherwise.
+func setClientAuthentication(data url.Values, client *config.Client, endpoints *config.OIDCConfiguration) {
+	if err := addClientAuthentication(data, client, endpoints); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// addClientAuthentication is setClientAuthentication for callers that have to
+// keep running
+func addClientAuthentication(data url.Values, client *config.Client, endpoints *config.OIDCConfiguration) error {
+	if usesPrivateKeyJWT(client) {
+		assertion, err := createClientAssertion(client, endpoints.Issuer)
+		if err != nil {
+			return err
+		}
+		data.Set("client_assertion", assertion)
+		data.Set("client_assertion_type", clientAssertionType)
+		return nil
+	}
+	if client.ClientSecret != "" {
+		secret, err := resolveSecret(client, client.ClientSecret)
+		if err != nil {
+			return err
+		}
+		data.Set("client_secret", secret)
+	}
+	return nil
+}
+
+// getClientAssertion signs a short lived client assertion for the client, or
+// exits if the private key cannot be used. auth0 expects the tenant's issuer
+// as the assertion's audience.
+func getClientAssertion(client *config.Client, audience string) string {
+	assertion, err := createClientAssertion(client, audience)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return assertion
+}
+
+// createClientAssertion is getClientAssertion for callers that have to keep
+// running
+func createClientAssertion(client *config.Client, audience string) (string, error) {
+	assertion, err := newClientAssertion(client, audience)
+	if err != nil {
+		return "", fmt.Errorf("could not create client assertion with %s - %v", client.PrivateKeyPath, err)
+	}
+	return assertion, nil
+}
+
+// newClientAssertion builds the JWT auth0 expects for Private Key JWT client
+// authentication, signed with the client's private key.
+func newClientAssertion(client *config.Client, audience string) (string, error) {
+	key, err := loadPrivateKey(client.PrivateKeyPath)
+	if err != nil {
+		return "", err
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": client.PrivateKeyKid,
+	})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss": client.ClientId,
+		"sub": client.ClientId,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+		"jti": hex.EncodeToString(jti),
+	})
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// loadPrivateKey reads an RSA private key from a PKCS#1 or PKCS#8 PEM file
+func loadPrivateKey(file string) (*rsa.PrivateKey, error) {
+	file, err := homedir.Expand(file)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block type %s", block.Type)
+}
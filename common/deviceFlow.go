@@ -0,0 +1,129 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+const (
+	OAuthDeviceCodePattern = "/oauth/device/code"
+	deviceCodeGrantType    = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+type auth0DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// getUserTokenDevice implements the device authorization grant. The user
+// finishes the login on any device with a browser, so it works over ssh and
+// inside containers.
+func getUserTokenDevice(client *config.Client, endpoints *config.OIDCConfiguration, audience string, scope string, params url.Values, opts TokenOptions) *auth0TokenSuccessResponse {
+	exitIfNoPrompt(opts, "client %s needs a device login, run 'spsauth0 client token' for it first so its refresh token can be used", client.ClientName)
+	warnUnsupportedGrant(endpoints, deviceCodeGrantType)
+
+	data := url.Values{}
+	data.Set("client_id", client.ClientId)
+	data.Set("audience", audience)
+	if scope != "" {
+		data.Set("scope", scope)
+	}
+	addParams(data, getTokenParams(params))
+
+	res, err := httpClient.PostForm(requireEndpoint("device authorization", endpoints.DeviceAuthorizationEndpoint), data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error executing http request: %v", err)
+		os.Exit(1)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var body auth0TokenErrorResponse
+		json.NewDecoder(res.Body).Decode(&body)
+		defer res.Body.Close()
+		fmt.Fprintf(os.Stderr, "Call to obtain device code returned non-OK status %d: %v\n", res.StatusCode, body)
+		os.Exit(1)
+	}
+
+	var deviceCode auth0DeviceCodeResponse
+	err = json.NewDecoder(res.Body).Decode(&deviceCode)
+	defer res.Body.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not read device code response - %v\n", err)
+		os.Exit(1)
+	}
+
+	// the instructions go to stderr so stdout only ever carries the token
+	fmt.Fprintf(os.Stderr, "To log in, visit %s and enter the code %s\n", deviceCode.VerificationURI, deviceCode.UserCode)
+	if deviceCode.VerificationURIComplete != "" {
+		fmt.Fprintf(os.Stderr, "or open %s\n", deviceCode.VerificationURIComplete)
+	}
+
+	return pollDeviceToken(client, endpoints, &deviceCode)
+}
+
+// pollDeviceToken polls the token endpoint until the user has approved or
+// denied the login, or the device code expires.
+func pollDeviceToken(client *config.Client, endpoints *config.OIDCConfiguration, deviceCode *auth0DeviceCodeResponse) *auth0TokenSuccessResponse {
+	interval := time.Duration(deviceCode.Interval) * time.Second
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	expiresIn := time.Duration(deviceCode.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 900 * time.Second
+	}
+	deadline := time.Now().Add(expiresIn)
+
+	data := url.Values{}
+	data.Set("grant_type", deviceCodeGrantType)
+	data.Set("client_id", client.ClientId)
+	data.Set("device_code", deviceCode.DeviceCode)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		res, err := httpClient.PostForm(requireEndpoint("token", endpoints.TokenEndpoint), data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error executing http request: %v", err)
+			os.Exit(1)
+		}
+
+		if res.StatusCode == http.StatusOK {
+			var body auth0TokenSuccessResponse
+			err = json.NewDecoder(res.Body).Decode(&body)
+			res.Body.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: could not read token response - %v\n", err)
+				os.Exit(1)
+			}
+			return &body
+		}
+
+		var body auth0TokenErrorResponse
+		json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+
+		switch body.Error {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			fmt.Fprintf(os.Stderr, "Call to obtain auth0 token returned non-OK status %d: %v\n", res.StatusCode, body)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "The device code expired before the login was completed.")
+	os.Exit(1)
+	return nil
+}
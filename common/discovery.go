@@ -0,0 +1,113 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+const (
+	OIDCDiscoveryPattern = "/.well-known/openid-configuration"
+)
+
+// TenantBaseURL returns the URL the tenant is served from. Domains are served
+// over https unless they name their own scheme, which allows pointing a tenant
+// at a local mock server.
+func TenantBaseURL(tenant *config.Tenant) string {
+	domain := strings.TrimSuffix(tenant.Tenant.Domain, "/")
+	if strings.HasPrefix(domain, "http://") || strings.HasPrefix(domain, "https://") {
+		return domain
+	}
+	return "https://" + domain
+}
+
+// GetTenantEndpoints returns the tenant's OIDC configuration, from the
+// discovery cache when it is fresh and from its openid-configuration
+// otherwise. If discovery fails the auth0 default endpoints are used.
+func GetTenantEndpoints(tenant *config.Tenant) *config.OIDCConfiguration {
+	discoveryCache, err := config.LoadDiscoveryCacheWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not load discovery cache - %v\n", err)
+	} else if oidc := discoveryCache.GetConfiguration(tenant.Tenant.Name); oidc != nil {
+		return oidc
+	}
+
+	oidc, err := fetchOIDCConfiguration(TenantBaseURL(tenant))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: OIDC discovery for tenant %s failed, using the auth0 default endpoints - %v\n",
+			tenant.Tenant.Name, err)
+		return defaultOIDCConfiguration(TenantBaseURL(tenant))
+	}
+
+	err = config.UpdateDiscoveryCacheWithViper(func(discoveryCache *config.DiscoveryCache) {
+		discoveryCache.SetConfiguration(tenant.Tenant.Name, oidc)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save discovery cache - %v\n", err)
+	}
+	return oidc
+}
+
+func fetchOIDCConfiguration(baseURL string) (*config.OIDCConfiguration, error) {
+	res, err := httpClient.Get(baseURL + OIDCDiscoveryPattern)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("call to obtain the openid-configuration returned non-OK status %d", res.StatusCode)
+	}
+
+	var oidc config.OIDCConfiguration
+	if err := json.NewDecoder(res.Body).Decode(&oidc); err != nil {
+		return nil, err
+	}
+	oidc.FetchedAt = time.Now().Unix()
+	return &oidc, nil
+}
+
+// defaultOIDCConfiguration returns the endpoints every auth0 tenant serves
+func defaultOIDCConfiguration(baseURL string) *config.OIDCConfiguration {
+	return &config.OIDCConfiguration{
+		Issuer:                      baseURL + "/",
+		AuthorizationEndpoint:       baseURL + "/authorize",
+		TokenEndpoint:               baseURL + OAuthTokenPattern,
+		DeviceAuthorizationEndpoint: baseURL + OAuthDeviceCodePattern,
+		RevocationEndpoint:          baseURL + "/oauth/revoke",
+		UserinfoEndpoint:            baseURL + "/userinfo",
+		JwksURI:                     baseURL + JWKSPattern,
+	}
+}
+
+// requireEndpoint exits if the tenant does not advertise the endpoint a flow needs
+func requireEndpoint(name string, endpoint string) string {
+	endpoint, err := checkEndpoint(name, endpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "The %v.\n", err)
+		os.Exit(1)
+	}
+	return endpoint
+}
+
+// checkEndpoint is requireEndpoint for callers that have to keep running
+func checkEndpoint(name string, endpoint string) (string, error) {
+	if endpoint == "" {
+		return "", fmt.Errorf("tenant does not advertise a %s endpoint in its openid-configuration", name)
+	}
+	return endpoint, nil
+}
+
+// warnUnsupportedGrant warns when the tenant does not advertise a grant type
+// the flow is about to use. The request is still made as discovery documents
+// do not always list everything a tenant allows.
+func warnUnsupportedGrant(oidc *config.OIDCConfiguration, grantType string) {
+	if !oidc.SupportsGrantType(grantType) {
+		fmt.Fprintf(os.Stderr, "Warning: the tenant does not advertise support for the %s grant\n", grantType)
+	}
+}
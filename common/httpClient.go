@@ -0,0 +1,10 @@
+package common
+
+import (
+	"net/http"
+	"time"
+)
+
+// httpClient makes every request to auth0, so a tenant that does not answer
+// fails the command instead of hanging it
+var httpClient = &http.Client{Timeout: 10 * time.Second}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+const (
+	JWKSPattern = "/.well-known/jwks.json"
+)
+
+// JWKS is a JSON Web Key Set as served by auth0
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a single JSON Web Key. Only RSA keys are used by auth0 to sign tokens.
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+var rsaSigningAlgs = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// FetchJWKS downloads the signing keys published at the tenant's jwks_uri
+func FetchJWKS(jwksURI string) (*JWKS, error) {
+	res, err := httpClient.Get(jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("call to obtain JWKS returned non-OK status %d", res.StatusCode)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+	return &jwks, nil
+}
+
+// LoadJWKSFile reads a JWKS from a local file, for verifying tokens offline
+func LoadJWKSFile(file string) (*JWKS, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+	return &jwks, nil
+}
+
+// PublicKey returns the RSA public key with the given kid
+func (s *JWKS) PublicKey(kid string) (*rsa.PublicKey, error) {
+	for _, key := range s.Keys {
+		if key.Kid != kid {
+			continue
+		}
+		if key.Kty != "RSA" {
+			return nil, fmt.Errorf("key %s has unsupported type %s", kid, key.Kty)
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid modulus: %v", kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid exponent: %v", kid, err)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	}
+	return nil, fmt.Errorf("no key with kid %s in JWKS", kid)
+}
+
+// VerifySignature checks the token was signed by one of the keys in the JWKS
+func (t *JWT) VerifySignature(jwks *JWKS) error {
+	alg := t.HeaderString("alg")
+	hash, ok := rsaSigningAlgs[alg]
+	if !ok {
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	key, err := jwks.PublicKey(t.HeaderString("kid"))
+	if err != nil {
+		return err
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(t.SigningInput()))
+	if err := rsa.VerifyPKCS1v15(key, hash, hasher.Sum(nil), t.Signature); err != nil {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// ValidateTimes checks the token is not expired and already valid, allowing
+// for skew between the local clock and auth0's.
+func (t *JWT) ValidateTimes(skew time.Duration) error {
+	now := time.Now()
+
+	exp, ok := t.TimeClaim("exp")
+	if !ok {
+		return errors.New("token has no exp claim")
+	}
+	if now.After(exp.Add(skew)) {
+		return fmt.Errorf("token expired at %s", exp.Local().Format(time.RFC1123))
+	}
+
+	if nbf, ok := t.TimeClaim("nbf"); ok && now.Add(skew).Before(nbf) {
+		return fmt.Errorf("token is not valid before %s", nbf.Local().Format(time.RFC1123))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package common
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signTestJWT returns a compact RS256 JWT with the claims, signed by key
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	input := encode(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encode(claims)
+	hasher := crypto.SHA256.New()
+	hasher.Write([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hasher.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func testJWKS(kid string, key *rsa.PublicKey) *JWKS {
+	return &JWKS{Keys: []JWK{{
+		Kid: kid,
+		Kty: "RSA",
+		Alg: "RS256",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := testJWKS("k1", &key.PublicKey)
+	raw := signTestJWT(t, key, "k1", map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+
+	token, err := DecodeJWT("Bearer " + raw)
+	if err != nil {
+		t.Fatalf("DecodeJWT: %v", err)
+	}
+	if err := token.VerifySignature(jwks); err != nil {
+		t.Errorf("VerifySignature of a valid token: %v", err)
+	}
+
+	parts := strings.Split(raw, ".")
+	tampered, err := DecodeJWT(parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory"}`)) + "." + parts[2])
+	if err != nil {
+		t.Fatalf("DecodeJWT: %v", err)
+	}
+	if err := tampered.VerifySignature(jwks); err == nil {
+		t.Error("VerifySignature accepted changed claims")
+	}
+
+	if err := token.VerifySignature(testJWKS("k1", &other.PublicKey)); err == nil {
+		t.Error("VerifySignature accepted a token signed with another key")
+	}
+	if err := token.VerifySignature(testJWKS("k2", &key.PublicKey)); err == nil {
+		t.Error("VerifySignature accepted a token whose kid is not in the JWKS")
+	}
+}
+
+func TestValidateTimes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	cases := []struct {
+		name   string
+		claims map[string]interface{}
+		valid  bool
+	}{
+		{"valid", map[string]interface{}{"exp": now.Add(time.Hour).Unix()}, true},
+		{"expired", map[string]interface{}{"exp": now.Add(-time.Hour).Unix()}, false},
+		{"expired within the skew", map[string]interface{}{"exp": now.Add(-10 * time.Second).Unix()}, true},
+		{"not yet valid", map[string]interface{}{"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(time.Hour).Unix()}, false},
+		{"no exp", map[string]interface{}{"sub": "alice"}, false},
+	}
+	for _, c := range cases {
+		token, err := DecodeJWT(signTestJWT(t, key, "k1", c.claims))
+		if err != nil {
+			t.Fatalf("%s: DecodeJWT: %v", c.name, err)
+		}
+		if err := token.ValidateTimes(30 * time.Second); (err == nil) != c.valid {
+			t.Errorf("%s: ValidateTimes = %v, want valid %v", c.name, err, c.valid)
+		}
+	}
+}
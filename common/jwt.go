@@ -0,0 +1,108 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JWT is a decoded JSON Web Token. Decoding does not verify the signature.
+type JWT struct {
+	Raw       string
+	Header    map[string]interface{}
+	Claims    map[string]interface{}
+	Signature []byte
+}
+
+// DecodeJWT splits a compact serialized JWT into its header, claims and
+// signature. A leading "Bearer " is ignored so Authorization headers can be
+// pasted as is.
+func DecodeJWT(raw string) (*JWT, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimSpace(strings.TrimPrefix(raw, "Bearer "))
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT, expected three '.' separated parts")
+	}
+
+	token := &JWT{Raw: raw}
+	if err := decodeJWTPart(parts[0], &token.Header); err != nil {
+		return nil, fmt.Errorf("could not decode header: %v", err)
+	}
+	if err := decodeJWTPart(parts[1], &token.Claims); err != nil {
+		return nil, fmt.Errorf("could not decode claims: %v", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("could not decode signature: %v", err)
+	}
+	token.Signature = signature
+
+	return token, nil
+}
+
+func decodeJWTPart(part string, v *map[string]interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	// keep numbers as they are so large ints and timestamps are not turned
+	// into floats when printed again
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// SigningInput returns the part of the token the signature is computed over
+func (t *JWT) SigningInput() string {
+	return t.Raw[:strings.LastIndex(t.Raw, ".")]
+}
+
+// HeaderString returns the header parameter name, or "" if it is not a string
+func (t *JWT) HeaderString(name string) string {
+	v, _ := t.Header[name].(string)
+	return v
+}
+
+// StringClaim returns the claim name, or "" if it is not a string
+func (t *JWT) StringClaim(name string) string {
+	v, _ := t.Claims[name].(string)
+	return v
+}
+
+// Audiences returns the aud claim, which may be a single string or a list
+func (t *JWT) Audiences() []string {
+	switch aud := t.Claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		list := make([]string, 0, len(aud))
+		for _, v := range aud {
+			if s, ok := v.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// TimeClaim returns a NumericDate claim such as iat, exp or nbf as a time
+func (t *JWT) TimeClaim(name string) (time.Time, bool) {
+	n, ok := t.Claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
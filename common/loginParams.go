@@ -0,0 +1,71 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// authorizeOnlyParams are login parameters that only mean something to the
+// authorization endpoint and are not sent along with token requests
+var authorizeOnlyParams = []string{"connection", "prompt", "screen_hint", "login_hint", "max_age"}
+
+// getLoginParams returns the auth0 login parameters and custom parameters to
+// send with the login. Each one comes from the command line, then the
+// client's defaults. Custom parameters from the command line are added to the
+// client's and replace those with the same name.
+func getLoginParams(client *config.Client, opts TokenOptions) url.Values {
+	params, err := parseLoginParams(client, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return params
+}
+
+// parseLoginParams is getLoginParams for callers that have to keep running
+func parseLoginParams(client *config.Client, opts TokenOptions) (url.Values, error) {
+	params := url.Values{}
+	set := func(name string, values ...string) {
+		if value := firstNonEmpty(values...); value != "" {
+			params.Set(name, value)
+		}
+	}
+	set("organization", opts.Organization, client.Organization)
+	set("connection", opts.Connection, client.Connection)
+	set("prompt", opts.Prompt, client.Prompt)
+	set("screen_hint", opts.ScreenHint, client.ScreenHint)
+	set("login_hint", opts.LoginHint, client.LoginHint)
+	set("max_age", opts.MaxAge, client.MaxAge)
+
+	for _, param := range append(append([]string{}, client.ExtraParams...), opts.ExtraParams...) {
+		name := strings.SplitN(param, "=", 2)
+		if len(name) != 2 || name[0] == "" {
+			return nil, fmt.Errorf("bad parameter %s, parameters are given as name=value", param)
+		}
+		params.Set(name[0], name[1])
+	}
+	return params, nil
+}
+
+// getTokenParams returns the login parameters sent to the token endpoint
+func getTokenParams(params url.Values) url.Values {
+	tokenParams := url.Values{}
+	for name, values := range params {
+		tokenParams[name] = values
+	}
+	for _, name := range authorizeOnlyParams {
+		tokenParams.Del(name)
+	}
+	return tokenParams
+}
+
+// addParams adds every parameter to data, replacing what is already there
+func addParams(data url.Values, params url.Values) {
+	for name, values := range params {
+		data[name] = values
+	}
+}
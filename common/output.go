@@ -0,0 +1,93 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Formats a token can be printed in
+const (
+	OutputRaw    = "raw"
+	OutputJSON   = "json"
+	OutputEnv    = "env"
+	OutputHeader = "header"
+)
+
+// TokenEnvVar is the environment variable tokens are handed to other programs in
+const TokenEnvVar = "SPSAUTH0_TOKEN"
+
+func GetSupportedOutputFormats() []string {
+	return []string{OutputRaw, OutputJSON, OutputEnv, OutputHeader}
+}
+
+// IsSupportedOutputFormat reports whether FormatToken can print a token in
+// the format
+func IsSupportedOutputFormat(format string) bool {
+	if format == "" {
+		return true
+	}
+	for _, supported := range GetSupportedOutputFormats() {
+		if strings.ToLower(format) == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatToken returns the token in one of the output formats: the bare access
+// token, the full token response as JSON, a shell export or an HTTP header.
+func FormatToken(token *TokenResponse, format string) string {
+	switch strings.ToLower(format) {
+	case "", OutputRaw:
+		return token.AccessToken
+	case OutputJSON:
+		output := struct {
+			*TokenResponse
+			Expiry string `json:"expiry,omitempty"`
+		}{TokenResponse: token}
+		if token.ExpiresAt > 0 {
+			output.Expiry = time.Unix(token.ExpiresAt, 0).UTC().Format(time.RFC3339)
+		}
+		b, _ := json.MarshalIndent(output, "", "  ")
+		return string(b)
+	case OutputEnv:
+		return "export " + TokenEnvVar + "=" + shellQuote(token.AccessToken)
+	case OutputHeader:
+		return "Authorization: Bearer " + token.AccessToken
+	}
+
+	fmt.Fprintf(os.Stderr, "Unsupported output format %s, use one of %v\n", format, GetSupportedOutputFormats())
+	os.Exit(1)
+	return ""
+}
+
+// clipboardCommands are the commands that write stdin to the system clipboard,
+// tried in order
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+// CopyToClipboard writes text to the system clipboard using the first clipboard
+// command found on the PATH.
+func CopyToClipboard(text string) error {
+	for _, command := range clipboardCommands {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+
+		cmd := exec.Command(path, command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard command found, install xclip, xsel or wl-clipboard")
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+const (
+	passwordGrantType      = "password"
+	passwordRealmGrantType = "http://auth0.com/oauth/grant-type/password-realm"
+)
+
+// getUserTokenPassword implements the resource owner password grant, for
+// getting tokens for test users without a browser. When the client has a
+// realm set, the password-realm grant is used to log in with that connection.
+func getUserTokenPassword(client *config.Client, endpoints *config.OIDCConfiguration, username string, audience string, scope string, params url.Values, opts TokenOptions) *auth0TokenSuccessResponse {
+	password := getTestUserPassword(client, opts)
+
+	data := url.Values{}
+	data.Set("grant_type", passwordGrantType)
+	data.Set("client_id", client.ClientId)
+	data.Set("username", username)
+	data.Set("password", password)
+	data.Set("audience", audience)
+	if scope != "" {
+		data.Set("scope", scope)
+	}
+	if client.Realm != "" {
+		data.Set("grant_type", passwordRealmGrantType)
+		data.Set("realm", client.Realm)
+	}
+	addParams(data, getTokenParams(params))
+	setClientAuthentication(data, client, endpoints)
+	warnUnsupportedGrant(endpoints, data.Get("grant_type"))
+
+	res, err := httpClient.PostForm(requireEndpoint("token", endpoints.TokenEndpoint), data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error executing http request: %v", err)
+		os.Exit(1)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var body auth0TokenErrorResponse
+		json.NewDecoder(res.Body).Decode(&body)
+		defer res.Body.Close()
+		fmt.Fprintf(os.Stderr, "Call to obtain auth0 token returned non-OK status %d: %v\n", res.StatusCode, body)
+		os.Exit(1)
+	}
+
+	var body auth0TokenSuccessResponse
+	err = json.NewDecoder(res.Body).Decode(&body)
+	defer res.Body.Close()
+
+	return &body
+}
+
+// testUsername returns the test user the password flow logs in as, or "" for
+// the other flows, whose user is only known once logged in. Flags win over
+// environment variables, which win over the user stored on the client.
+func testUsername(client *config.Client, opts TokenOptions) string {
+	if client.ClientType == "Machine-to-Machine Application" || firstNonEmpty(opts.Flow, client.Flow) != config.FlowPassword {
+		return ""
+	}
+	return firstNonEmpty(opts.Username, os.Getenv(config.EnvTestUsername), client.Username)
+}
+
+// getTestUsername is testUsername asking for the test user when the password
+// flow has none. Tokens are cached per test user, so it is settled before the
+// cache is looked at.
+func getTestUsername(client *config.Client, opts TokenOptions) string {
+	username := testUsername(client, opts)
+	if username != "" || client.ClientType == "Machine-to-Machine Application" || getFlow(client, opts) != config.FlowPassword {
+		return username
+	}
+
+	exitIfNoPrompt(opts, "client %s has no test user, set %s or give the client one", client.ClientName, config.EnvTestUsername)
+	username, err := PromptString("Username", "", false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	return username
+}
+
+// getTestUserPassword returns the password of the test user, from the flags,
+// the environment or the client in that order, and prompts for it otherwise.
+func getTestUserPassword(client *config.Client, opts TokenOptions) string {
+	password := firstNonEmpty(opts.Password, os.Getenv(config.EnvTestPassword))
+
+	var err error
+	if password == "" {
+		password, err = resolveSecret(client, client.Password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if password == "" {
+		exitIfNoPrompt(opts, "the test user of client %s has no password, set %s or give the client one", client.ClientName, config.EnvTestPassword)
+		password, err = PromptPassword("Password")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	}
+	return password
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -3,9 +3,13 @@ package common
 import (
 	"errors"
 	"github.com/manifoldco/promptui"
+	"os"
 	"strings"
 )
 
+// All prompts are written to stderr so that stdout only carries the output of
+// the command, e.g. the token printed by 'client token'.
+
 func ValidateEmptyInput(input string) error {
 	if len(strings.TrimSpace(input)) < 1 {
 		return errors.New("this input must not be empty")
@@ -19,6 +23,30 @@ func PromptString(name string, currentValue string, isConfirm bool) (string, err
 		Validate: ValidateEmptyInput,
 		Default: currentValue,
 		IsConfirm: isConfirm,
+		Stdout:    os.Stderr,
+	}
+
+	return prompt.Run()
+}
+
+// PromptOptionalString prompts for a value that may be left empty
+func PromptOptionalString(name string, currentValue string) (string, error) {
+	prompt := promptui.Prompt{
+		Label:   name,
+		Default: currentValue,
+		Stdout:  os.Stderr,
+	}
+
+	return prompt.Run()
+}
+
+// PromptPassword prompts for a secret without echoing it
+func PromptPassword(name string) (string, error) {
+	prompt := promptui.Prompt{
+		Label:    name,
+		Validate: ValidateEmptyInput,
+		Mask:     '*',
+		Stdout:   os.Stderr,
 	}
 
 	return prompt.Run()
@@ -26,13 +54,57 @@ func PromptString(name string, currentValue string, isConfirm bool) (string, err
 
 func PromptSelect(name string, items []string) (int, string, error){
 	prompt := promptui.Select{
-		Label: name,
-		Items: items,
+		Label:  name,
+		Items:  items,
+		Stdout: os.Stderr,
 	}
 
 	return prompt.Run()
 }
 
+// PromptMultiSelect lets the user toggle any number of items on and off until
+// they pick Done, and returns the selected items in their original order.
+func PromptMultiSelect(name string, items []string) ([]string, error) {
+	selected := make([]bool, len(items))
+	cursor := 0
+	for {
+		options := []string{"Done"}
+		for i, item := range items {
+			mark := "[ ]"
+			if selected[i] {
+				mark = "[x]"
+			}
+			options = append(options, mark+" "+item)
+		}
+
+		prompt := promptui.Select{
+			Label:        name,
+			Items:        options,
+			CursorPos:    cursor,
+			Size:         10,
+			HideSelected: true,
+			Stdout:       os.Stderr,
+		}
+		i, _, err := prompt.Run()
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			break
+		}
+		selected[i-1] = !selected[i-1]
+		cursor = i
+	}
+
+	result := make([]string, 0)
+	for i, item := range items {
+		if selected[i] {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
 //func PromptInteger(name string) (int64, error) {
 //	prompt := promptui.Prompt{
 //		Label:    name,
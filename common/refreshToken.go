@@ -0,0 +1,167 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// supportsRefreshToken reports whether the flow for the client requests
+// offline_access and so gets a refresh token back.
+func supportsRefreshToken(client *config.Client) bool {
+	switch client.ClientType {
+	case "Native Application", "Web Service Application":
+		return true
+	}
+	return false
+}
+
+// refreshAccessToken exchanges the refresh token stored for the client and
+// test user for a new access token. It returns nil if there is no stored
+// refresh token for the audience, scope and login parameters or auth0 rejects
+// it, in which case the caller falls back to the browser flow.
+func refreshAccessToken(client *config.Client, endpoints *config.OIDCConfiguration, username string, audience string, scope string, params string) *auth0TokenSuccessResponse {
+	store, err := config.LoadRefreshTokenStoreWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not load refresh tokens - %v\n", err)
+		return nil
+	}
+
+	stored := store.GetRefreshToken(client.TenantName, client.ClientName, audience, scope, params, username)
+	if stored == nil {
+		return nil
+	}
+
+	refreshToken, err := resolveSecret(client, stored.RefreshToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read the refresh token - %v\n", err)
+		return nil
+	}
+
+	token, err := exchangeRefreshToken(client, endpoints, refreshToken)
+	if err == errRefreshTokenInvalid {
+		// the refresh token was revoked, expired or already rotated away so
+		// there is no point in keeping it around
+		forgetRefreshToken(stored)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not refresh the access token - %v\n", err)
+		return nil
+	}
+	return token
+}
+
+// errRefreshTokenInvalid means auth0 will not take the refresh token again
+var errRefreshTokenInvalid = errors.New("the refresh token is no longer valid")
+
+// exchangeRefreshToken trades a refresh token for a new access token
+func exchangeRefreshToken(client *config.Client, endpoints *config.OIDCConfiguration, refreshToken string) (*auth0TokenSuccessResponse, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("client_id", client.ClientId)
+	data.Set("refresh_token", refreshToken)
+	if client.ClientType == "Web Service Application" {
+		if err := addClientAuthentication(data, client, endpoints); err != nil {
+			return nil, err
+		}
+	}
+
+	tokenURL, err := checkEndpoint("token", endpoints.TokenEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpClient.PostForm(tokenURL, data)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var body auth0TokenErrorResponse
+		json.NewDecoder(res.Body).Decode(&body)
+		if body.Error == "invalid_grant" {
+			return nil, errRefreshTokenInvalid
+		}
+		return nil, fmt.Errorf("refreshing the access token returned non-OK status %d: %v", res.StatusCode, body)
+	}
+
+	var body auth0TokenSuccessResponse
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil || body.AccessToken == "" {
+		return nil, fmt.Errorf("could not read the refreshed access token - %v", err)
+	}
+	return &body, nil
+}
+
+// storeRefreshToken keeps the refresh token auth0 returned for the client.
+// With refresh token rotation enabled every exchange returns a new refresh
+// token and invalidates the old one, so the token stored for the same
+// audience, scope and login parameters is replaced. Logins for anything else
+// keep their own refresh token.
+func storeRefreshToken(client *config.Client, username string, audience string, scope string, params string, token *auth0TokenSuccessResponse) {
+	if token == nil || token.RefreshToken == "" {
+		return
+	}
+
+	stored := &config.StoredRefreshToken{
+		TenantName: client.TenantName,
+		ClientName: client.ClientName,
+		Username:   username,
+		Audience:   audience,
+		Scope:      scope,
+		Params:     params,
+		IssuedAt:   time.Now().Unix(),
+	}
+	// with a secret backend configured the store only refers to the token
+	refreshToken, err := storeSecret(config.RefreshTokenSecretKey(token.RefreshToken), token.RefreshToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not store the refresh token - %v\n", err)
+		return
+	}
+	stored.RefreshToken = refreshToken
+
+	var replaced *config.StoredRefreshToken
+	err = config.UpdateRefreshTokenStoreWithViper(func(store *config.RefreshTokenStore) {
+		replaced = store.GetRefreshToken(client.TenantName, client.ClientName, audience, scope, params, username)
+		store.SetRefreshToken(stored)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save refresh tokens - %v\n", err)
+		return
+	}
+	// the token it replaces is of no use any more
+	if replaced != nil && replaced.RefreshToken != stored.RefreshToken {
+		if err := DeleteSecret(replaced.RefreshToken); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not remove the replaced refresh token from the secret store - %v\n", err)
+		}
+	}
+}
+
+// forgetRefreshToken removes a refresh token auth0 no longer takes from the
+// store and the secret backend, unless another command stored a new one for
+// the same login in the meantime
+func forgetRefreshToken(stored *config.StoredRefreshToken) {
+	forgotten := false
+	err := config.UpdateRefreshTokenStoreWithViper(func(store *config.RefreshTokenStore) {
+		current := store.GetRefreshToken(stored.TenantName, stored.ClientName, stored.Audience, stored.Scope, stored.Params, stored.Username)
+		if current != nil && current.RefreshToken == stored.RefreshToken {
+			store.DeleteRefreshToken(current)
+			forgotten = true
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save refresh tokens - %v\n", err)
+		return
+	}
+	if forgotten {
+		if err := DeleteSecret(stored.RefreshToken); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not remove the refresh token from the secret store - %v\n", err)
+		}
+	}
+}
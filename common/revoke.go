@@ -0,0 +1,43 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// RevokeRefreshToken asks the client's tenant to revoke a refresh token issued
+// to the client, authenticating with the client's secret or private key. The
+// refresh token may refer to the secret backend it is kept in. The access
+// tokens it was used for stay valid until they expire.
+func RevokeRefreshToken(client *config.Client, refreshToken string) error {
+	refreshToken, err := resolveSecret(client, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	_, tenant := loadClientTenant(client)
+	endpoints := GetTenantEndpoints(tenant)
+
+	data := url.Values{}
+	data.Set("client_id", client.ClientId)
+	data.Set("token", refreshToken)
+	data.Set("token_type_hint", "refresh_token")
+	setClientAuthentication(data, client, endpoints)
+
+	res, err := httpClient.PostForm(requireEndpoint("revocation", endpoints.RevocationEndpoint), data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var body auth0TokenErrorResponse
+		json.NewDecoder(res.Body).Decode(&body)
+		return fmt.Errorf("revocation returned non-OK status %d: %v", res.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// unlockMu has concurrent lookups ask for the vault passphrase only once
+var unlockMu sync.Mutex
+
+// ResolveClientSecrets returns the client with its secrets read from where
+// its config refers to, see resolveSecret. Token requests read the secret they
+// need themselves.
+func ResolveClientSecrets(client *config.Client) *config.Client {
+	if client == nil {
+		return nil
+	}
+
+	resolved := *client
+	for _, field := range []*string{&resolved.ClientSecret, &resolved.Password} {
+		secret, err := resolveSecret(client, *field)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		*field = secret
+	}
+	return &resolved
+}
+
+// resolveSecret returns the secret a value in the client's config refers to.
+// When the vault is locked for this process the running agent is asked for
+// the secret, and the vault is unlocked with its passphrase when the agent
+// does not hold the key either. It is called right before the secret is sent
+// so a locked vault only gets in the way when a secret is needed.
+func resolveSecret(client *config.Client, value string) (string, error) {
+	secret, err := config.ResolveSecret(value)
+	if errors.Is(err, config.ErrVaultLocked) {
+		if agentSecret, agentErr := requestAgentSecret(value); agentErr == nil {
+			return agentSecret, nil
+		}
+
+		unlockMu.Lock()
+		defer unlockMu.Unlock()
+
+		// another lookup may have unlocked it in the meantime
+		secret, err = config.ResolveSecret(value)
+		if errors.Is(err, config.ErrVaultLocked) {
+			if err := unlockVault(); err != nil {
+				return "", err
+			}
+			secret, err = config.ResolveSecret(value)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read the secrets of client %s - %w", client.ClientName, err)
+	}
+	return secret, nil
+}
+
+// storeSecret keeps a secret spsauth0 obtained, such as a refresh token, in
+// the configured backend and returns the value to save in its place. A vault
+// locked for this process is written by the agent or unlocked, like in
+// resolveSecret.
+func storeSecret(key string, secret string) (string, error) {
+	ref, err := config.StoreSecret(key, secret)
+	if errors.Is(err, config.ErrVaultLocked) {
+		if agentRef, agentErr := requestAgentStoreSecret(key, secret); agentErr == nil {
+			return agentRef, nil
+		}
+
+		unlockMu.Lock()
+		defer unlockMu.Unlock()
+
+		ref, err = config.StoreSecret(key, secret)
+		if errors.Is(err, config.ErrVaultLocked) {
+			if err := unlockVault(); err != nil {
+				return "", err
+			}
+			ref, err = config.StoreSecret(key, secret)
+		}
+	}
+	return ref, err
+}
+
+// DeleteSecret removes a secret spsauth0 no longer needs from its store,
+// having the agent do it when the vault is locked for this process. Nobody is
+// asked for the passphrase just to clean up.
+func DeleteSecret(value string) error {
+	err := config.DeleteSecret(value)
+	if errors.Is(err, config.ErrVaultLocked) {
+		if agentErr := requestAgentDeleteSecret(value); agentErr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// UnlockVault unlocks the vault for this process, see unlockVault
+func UnlockVault() {
+	if err := unlockVault(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// unlockVault unlocks the vault for this process by asking for the passphrase
+func unlockVault() error {
+	passphrase, err := PromptPassword("Vault passphrase")
+	if err != nil {
+		return fmt.Errorf("%v (%v)", config.ErrVaultLocked, err)
+	}
+	_, err = config.UnlockVault(passphrase)
+	return err
+}
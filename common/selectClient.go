@@ -0,0 +1,35 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// SelectClient returns the configured client with the given name, or prompts
+// for one of the configured clients when no name is given. Secrets kept in a
+// secret store are only read once a request needs them.
+func SelectClient(clientName string) *config.Client {
+	clientConfig, err := config.LoadClientConfigWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not load client config - %v\n", err)
+		os.Exit(1)
+	}
+
+	if clientName == "" {
+		_, clientName, err = PromptSelect("Clients", config.GetClientListNames(*clientConfig.GetClientList("all")))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	}
+
+	client := clientConfig.GetClientConfig(strings.ToLower(clientName))
+	if client == nil {
+		fmt.Fprintf(os.Stderr, "Client %s does not exist, use 'spsauth0 client list' to list configured clients.\n", clientName)
+		os.Exit(1)
+	}
+	return client
+}
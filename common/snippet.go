@@ -0,0 +1,313 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// Languages a token request snippet can be generated in
+const (
+	SnippetCurl       = "curl"
+	SnippetHTTPie     = "httpie"
+	SnippetPython     = "python"
+	SnippetNode       = "node"
+	SnippetGo         = "go"
+	SnippetPowerShell = "powershell"
+)
+
+func GetSupportedSnippetLanguages() []string {
+	return []string{SnippetCurl, SnippetHTTPie, SnippetPython, SnippetNode, SnippetGo, SnippetPowerShell}
+}
+
+// snippetParam is one parameter of the request body, kept in a slice so the
+// snippet lists them in the order the request is built
+type snippetParam struct {
+	Name  string
+	Value string
+}
+
+// snippetRequest is the token request a snippet reproduces
+type snippetRequest struct {
+	URL    string
+	JSON   bool
+	Params []snippetParam
+}
+
+func (r *snippetRequest) add(name string, value string) {
+	r.Params = append(r.Params, snippetParam{Name: name, Value: value})
+}
+
+// GetTokenSnippet returns a ready to run request, in the given language, for
+// the request spsauth0 would make to get a token for the client. Secrets are
+// replaced by placeholders unless includeSecrets is set. Browser flows get the
+// code exchange, with the authorization code left as a placeholder.
+func GetTokenSnippet(client *config.Client, opts TokenOptions, language string, includeSecrets bool) string {
+	render := snippetRenderer(language)
+	if includeSecrets {
+		client = ResolveClientSecrets(client)
+	}
+
+	tenantConfig, tenant := loadClientTenant(client)
+	endpoints := GetTenantEndpoints(tenant)
+	audience := getAudience(client, tenant, tenantConfig, opts)
+	scope := getScope(client, tenant, audience, opts)
+	tokenParams := getTokenParams(getLoginParams(client, opts))
+
+	secret := func(value string, placeholder string) string {
+		if includeSecrets && value != "" {
+			return value
+		}
+		return placeholder
+	}
+
+	req := &snippetRequest{URL: requireEndpoint("token", endpoints.TokenEndpoint)}
+	addClientAuthentication := func() {
+		if usesPrivateKeyJWT(client) {
+			assertion := "<CLIENT_ASSERTION>"
+			if includeSecrets {
+				// the assertion is only valid for a minute, so the snippet
+				// has to be run right away
+				assertion = getClientAssertion(client, endpoints.Issuer)
+			}
+			req.add("client_assertion", assertion)
+			req.add("client_assertion_type", clientAssertionType)
+			return
+		}
+		if client.ClientSecret != "" || client.ClientType == "Machine-to-Machine Application" {
+			req.add("client_secret", secret(client.ClientSecret, "<CLIENT_SECRET>"))
+		}
+	}
+
+	switch {
+	case client.ClientType == "Machine-to-Machine Application":
+		// getClientToken posts the client credentials request as JSON
+		req.JSON = true
+		req.add("grant_type", "client_credentials")
+		req.add("client_id", client.ClientId)
+		addClientAuthentication()
+		req.add("audience", audience)
+		if scope != "" {
+			req.add("scope", scope)
+		}
+	case getFlow(client, opts) == config.FlowDevice:
+		req.URL = requireEndpoint("device authorization", endpoints.DeviceAuthorizationEndpoint)
+		req.add("client_id", client.ClientId)
+		req.add("audience", audience)
+		if scope != "" {
+			req.add("scope", scope)
+		}
+	case getFlow(client, opts) == config.FlowPassword:
+		username := firstNonEmpty(opts.Username, os.Getenv(config.EnvTestUsername), client.Username, "<USERNAME>")
+		password := firstNonEmpty(opts.Password, os.Getenv(config.EnvTestPassword), client.Password)
+		if client.Realm != "" {
+			req.add("grant_type", passwordRealmGrantType)
+		} else {
+			req.add("grant_type", passwordGrantType)
+		}
+		req.add("client_id", client.ClientId)
+		addClientAuthentication()
+		req.add("username", username)
+		req.add("password", secret(password, "<PASSWORD>"))
+		req.add("audience", audience)
+		if scope != "" {
+			req.add("scope", scope)
+		}
+		if client.Realm != "" {
+			req.add("realm", client.Realm)
+		}
+	case client.ClientType == "Single-Page Application (SPA)":
+		fmt.Fprintln(os.Stderr, "Single-Page Application clients get their token from the authorization endpoint, there is no token request to generate a snippet for")
+		os.Exit(1)
+	default:
+		req.add("grant_type", "authorization_code")
+		req.add("client_id", client.ClientId)
+		if client.ClientType == "Native Application" {
+			req.add("code_verifier", "<CODE_VERIFIER>")
+		} else {
+			addClientAuthentication()
+		}
+		req.add("code", "<AUTHORIZATION_CODE>")
+		req.add("redirect_uri", firstNonEmpty(opts.RedirectURI, client.RedirectURI, DefaultRedirectURI))
+	}
+
+	names := make([]string, 0, len(tokenParams))
+	for name := range tokenParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		req.add(name, tokenParams.Get(name))
+	}
+	return render(req)
+}
+
+func snippetRenderer(language string) func(*snippetRequest) string {
+	switch strings.ToLower(language) {
+	case SnippetCurl:
+		return curlSnippet
+	case SnippetHTTPie:
+		return httpieSnippet
+	case SnippetPython:
+		return pythonSnippet
+	case SnippetNode:
+		return nodeSnippet
+	case SnippetGo:
+		return goSnippet
+	case SnippetPowerShell:
+		return powershellSnippet
+	}
+	fmt.Fprintf(os.Stderr, "Unsupported snippet language %s, use one of %v\n", language, GetSupportedSnippetLanguages())
+	os.Exit(1)
+	return nil
+}
+
+// jsonBody returns the request parameters as a JSON object, in order
+func (r *snippetRequest) jsonBody() string {
+	fields := make([]string, 0, len(r.Params))
+	for _, p := range r.Params {
+		fields = append(fields, jsonString(p.Name)+":"+jsonString(p.Value))
+	}
+	return "{" + strings.Join(fields, ",") + "}"
+}
+
+// jsonString quotes s as a JSON string, leaving the <> of placeholders alone
+func jsonString(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// powershellQuote quotes s as a PowerShell literal string
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func curlSnippet(r *snippetRequest) string {
+	lines := []string{
+		"curl --request POST",
+		"  --url " + shellQuote(r.URL),
+	}
+	if r.JSON {
+		lines = append(lines,
+			"  --header 'content-type: application/json'",
+			"  --data "+shellQuote(r.jsonBody()))
+	} else {
+		lines = append(lines, "  --header 'content-type: application/x-www-form-urlencoded'")
+		for _, p := range r.Params {
+			lines = append(lines, "  --data-urlencode "+shellQuote(p.Name+"="+p.Value))
+		}
+	}
+	return strings.Join(lines, " \\\n")
+}
+
+func httpieSnippet(r *snippetRequest) string {
+	lines := []string{"http POST " + shellQuote(r.URL)}
+	if !r.JSON {
+		lines[0] = "http --form POST " + shellQuote(r.URL)
+	}
+	for _, p := range r.Params {
+		lines = append(lines, "  "+shellQuote(p.Name+"="+p.Value))
+	}
+	return strings.Join(lines, " \\\n")
+}
+
+func pythonSnippet(r *snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("import requests\n\n")
+	b.WriteString("response = requests.post(\n")
+	fmt.Fprintf(&b, "    %s,\n", jsonString(r.URL))
+	if r.JSON {
+		b.WriteString("    json={\n")
+	} else {
+		b.WriteString("    data={\n")
+	}
+	for _, p := range r.Params {
+		fmt.Fprintf(&b, "        %s: %s,\n", jsonString(p.Name), jsonString(p.Value))
+	}
+	b.WriteString("    },\n")
+	b.WriteString(")\n")
+	b.WriteString("print(response.json())")
+	return b.String()
+}
+
+func nodeSnippet(r *snippetRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "fetch(%s, {\n", jsonString(r.URL))
+	b.WriteString("  method: \"POST\",\n")
+	if r.JSON {
+		b.WriteString("  headers: { \"content-type\": \"application/json\" },\n")
+		b.WriteString("  body: JSON.stringify({\n")
+	} else {
+		b.WriteString("  body: new URLSearchParams({\n")
+	}
+	for _, p := range r.Params {
+		fmt.Fprintf(&b, "    %s: %s,\n", jsonString(p.Name), jsonString(p.Value))
+	}
+	b.WriteString("  }),\n")
+	b.WriteString("})\n")
+	b.WriteString("  .then((res) => res.json())\n")
+	b.WriteString("  .then(console.log);")
+	return b.String()
+}
+
+func goSnippet(r *snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("package main\n\n")
+	b.WriteString("import (\n")
+	b.WriteString("\t\"fmt\"\n")
+	b.WriteString("\t\"io\"\n")
+	b.WriteString("\t\"net/http\"\n")
+	if r.JSON {
+		b.WriteString("\t\"strings\"\n")
+	} else {
+		b.WriteString("\t\"net/url\"\n")
+	}
+	b.WriteString(")\n\n")
+	b.WriteString("func main() {\n")
+	if r.JSON {
+		fmt.Fprintf(&b, "\tbody := strings.NewReader(%s)\n", strconv.Quote(r.jsonBody()))
+		fmt.Fprintf(&b, "\tres, err := http.Post(%s, \"application/json\", body)\n", strconv.Quote(r.URL))
+	} else {
+		b.WriteString("\tdata := url.Values{}\n")
+		for _, p := range r.Params {
+			fmt.Fprintf(&b, "\tdata.Set(%s, %s)\n", strconv.Quote(p.Name), strconv.Quote(p.Value))
+		}
+		fmt.Fprintf(&b, "\tres, err := http.PostForm(%s, data)\n", strconv.Quote(r.URL))
+	}
+	b.WriteString("\tif err != nil {\n")
+	b.WriteString("\t\tpanic(err)\n")
+	b.WriteString("\t}\n")
+	b.WriteString("\tdefer res.Body.Close()\n\n")
+	b.WriteString("\tout, _ := io.ReadAll(res.Body)\n")
+	b.WriteString("\tfmt.Println(string(out))\n")
+	b.WriteString("}")
+	return b.String()
+}
+
+func powershellSnippet(r *snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("$body = [ordered]@{\n")
+	for _, p := range r.Params {
+		fmt.Fprintf(&b, "    %s = %s\n", powershellQuote(p.Name), powershellQuote(p.Value))
+	}
+	b.WriteString("}\n")
+	if r.JSON {
+		fmt.Fprintf(&b, "Invoke-RestMethod -Method Post -Uri %s -ContentType 'application/json' -Body ($body | ConvertTo-Json)", powershellQuote(r.URL))
+	} else {
+		fmt.Fprintf(&b, "Invoke-RestMethod -Method Post -Uri %s -ContentType 'application/x-www-form-urlencoded' -Body $body", powershellQuote(r.URL))
+	}
+	return b.String()
+}
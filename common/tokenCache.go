@@ -0,0 +1,99 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// getCachedToken returns a still valid token from the token cache, or nil.
+// Failing to read the cache is not fatal, a new token is requested instead.
+func getCachedToken(key string) *config.CachedToken {
+	tokenCache, err := config.LoadTokenCacheWithViper()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not load token cache - %v\n", err)
+		return nil
+	}
+	return tokenCache.GetToken(key)
+}
+
+// cacheToken stores a token returned by auth0 so later calls can reuse it
+// until it is close to expiry.
+func cacheToken(key string, client *config.Client, username string, audience string, scope string, params string, token *auth0TokenSuccessResponse) {
+	if token.AccessToken == "" || token.ExpiresIn == 0 {
+		return
+	}
+
+	cached := &config.CachedToken{
+		TenantName:   client.TenantName,
+		ClientName:   client.ClientName,
+		Username:     username,
+		Audience:     audience,
+		Scope:        scope,
+		Params:       params,
+		AccessToken:  token.AccessToken,
+		IDToken:      token.IDToken,
+		GrantedScope: token.Scope,
+		TokenType:    token.TokenType,
+		ExpiresIn:    token.ExpiresIn,
+		ExpiresAt:    time.Now().Add(time.Duration(token.ExpiresIn) * time.Second).Unix(),
+	}
+
+	err := config.UpdateTokenCacheWithViper(func(tokenCache *config.TokenCache) {
+		tokenCache.SetToken(key, cached)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save token cache - %v\n", err)
+	}
+}
+
+// usedScope returns the scope of the latest valid cached token, or else of the
+// latest stored refresh token, of the client for the audience, login
+// parameters and test user. It is the scope picked the last time, and a token
+// for it is to be had without the user.
+func usedScope(client *config.Client, audience string, params string, username string) string {
+	if tokenCache, err := config.LoadTokenCacheWithViper(); err == nil {
+		var latest *config.CachedToken
+		for _, token := range tokenCache.GetTokens(client.TenantName, client.ClientName, username) {
+			if token.Audience != audience || token.Params != params {
+				continue
+			}
+			if latest == nil || token.ExpiresAt > latest.ExpiresAt {
+				latest = token
+			}
+		}
+		if latest != nil {
+			return latest.Scope
+		}
+	}
+
+	if store, err := config.LoadRefreshTokenStoreWithViper(); err == nil {
+		var latest *config.StoredRefreshToken
+		for _, stored := range store.GetRefreshTokens(client.TenantName, client.ClientName, username) {
+			if stored.Audience != audience || stored.Params != params {
+				continue
+			}
+			if latest == nil || stored.IssuedAt > latest.IssuedAt {
+				latest = stored
+			}
+		}
+		if latest != nil {
+			return latest.Scope
+		}
+	}
+	return ""
+}
+
+// cachedTokenResponse returns a token served from the cache
+func cachedTokenResponse(token *config.CachedToken) *TokenResponse {
+	return &TokenResponse{
+		AccessToken: token.AccessToken,
+		IDToken:     token.IDToken,
+		TokenType:   token.TokenType,
+		Scope:       token.GrantedScope,
+		ExpiresIn:   token.ExpiresIn,
+		ExpiresAt:   token.ExpiresAt,
+	}
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// errRenewNeedsLogin means the token can only be renewed by logging in again
+var errRenewNeedsLogin = errors.New("the token can not be renewed without logging in again")
+
+// TokenSource hands out tokens for a client to long running commands. The
+// token is renewed once it gets within config.TokenExpiryLeeway of expiring.
+type TokenSource struct {
+	client    *config.Client
+	endpoints *config.OIDCConfiguration
+	audience  string
+	scope     string
+	params    url.Values
+	username  string
+	key       string
+
+	mu    sync.Mutex
+	token *TokenResponse
+}
+
+// NewTokenSource settles the audience and scope and gets the first token,
+// prompting and logging in as needed. Renewals never involve the user.
+func NewTokenSource(client *config.Client, opts TokenOptions) *TokenSource {
+	tenantConfig, tenant := loadClientTenant(client)
+	s := &TokenSource{
+		client:    client,
+		endpoints: GetTenantEndpoints(tenant),
+		params:    getLoginParams(client, opts),
+	}
+	s.audience = getAudience(client, tenant, tenantConfig, opts)
+	s.scope = getScope(client, tenant, s.audience, opts)
+	s.username = getTestUsername(client, opts)
+	s.key = config.TokenCacheKey(client.TenantName, client.ClientName, s.audience, s.scope, s.params.Encode(), s.username)
+
+	opts.Username = s.username
+	s.token = getTokenFor(client, s.endpoints, s.audience, s.scope, opts)
+	return s
+}
+
+// Token returns a token that is valid for at least config.TokenExpiryLeeway
+func (s *TokenSource) Token() (*TokenResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expiresSoon() {
+		token, err := s.renew()
+		if err != nil {
+			return nil, err
+		}
+		s.token = token
+	}
+	return s.token, nil
+}
+
+// renew gets a new token without involving the user: from the cache when
+// another command renewed it already, otherwise with client credentials or
+// the stored refresh token
+func (s *TokenSource) renew() (*TokenResponse, error) {
+	if cached := getCachedToken(s.key); cached != nil {
+		return cachedTokenResponse(cached), nil
+	}
+
+	var token *auth0TokenSuccessResponse
+	if s.client.ClientType == "Machine-to-Machine Application" {
+		var err error
+		token, err = requestClientToken(s.client, s.endpoints, s.audience, s.scope, s.params)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if supportsRefreshToken(s.client) {
+			token = refreshAccessToken(s.client, s.endpoints, s.username, s.audience, s.scope, s.params.Encode())
+		}
+		if token == nil {
+			return nil, errRenewNeedsLogin
+		}
+		storeRefreshToken(s.client, s.username, s.audience, s.scope, s.params.Encode(), token)
+	}
+
+	cacheToken(s.key, s.client, s.username, s.audience, s.scope, s.params.Encode(), token)
+	return newTokenResponse(token), nil
+}
+
+func (s *TokenSource) expiresSoon() bool {
+	return expiresWithin(s.token, config.TokenExpiryLeeway)
+}
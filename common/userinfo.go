@@ -0,0 +1,41 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/bluce-clj/spsauth0/internal/config"
+)
+
+// GetUserInfo calls the tenant's userinfo endpoint with an access token issued
+// to the client and returns the profile of the user that logged in. The token
+// has to have been requested with the openid scope.
+func GetUserInfo(client *config.Client, accessToken string) map[string]interface{} {
+	_, tenant := loadClientTenant(client)
+	endpoints := GetTenantEndpoints(tenant)
+
+	req, _ := http.NewRequest("GET", requireEndpoint("userinfo", endpoints.UserinfoEndpoint), nil)
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	res, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error executing http request: %v\n", err)
+		os.Exit(1)
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Call to userinfo returned non-OK status %d: %s\n", res.StatusCode, body)
+		os.Exit(1)
+	}
+
+	profile := make(map[string]interface{})
+	if err := json.Unmarshal(body, &profile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not read the userinfo response - %v\n", err)
+		os.Exit(1)
+	}
+	return profile
+}
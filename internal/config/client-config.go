@@ -3,6 +3,7 @@ package config
 import (
 	"github.com/spf13/viper"
 	"path"
+	"sort"
 	"strings"
 )
 
@@ -63,6 +64,33 @@ func GetSupportedClientTypes() []string {
 		"Machine-to-Machine Application"}
 }
 
+const (
+	ClientAuthSecret        = "Client Secret"
+	ClientAuthPrivateKeyJWT = "Private Key JWT"
+)
+
+// GetSupportedClientAuthMethods returns the ways a confidential client can
+// authenticate to the token endpoint
+func GetSupportedClientAuthMethods() []string {
+	return []string{ClientAuthSecret, ClientAuthPrivateKeyJWT}
+}
+
+const (
+	// FlowBrowser logs the user in with a local browser and callback server
+	FlowBrowser = "browser"
+	// FlowDevice logs the user in with the device authorization grant, for
+	// machines without a local browser
+	FlowDevice = "device"
+	// FlowPassword exchanges a test user's username and password for a token,
+	// using the client's realm when one is set
+	FlowPassword = "password"
+)
+
+// GetSupportedFlows returns the ways a user token can be obtained
+func GetSupportedFlows() []string {
+	return []string{FlowBrowser, FlowDevice, FlowPassword}
+}
+
 // GetTenantConfig returns the tenantConfig for the specified name or nil if the
 // tenant does not exist or config has not been loaded.
 func (c *ClientConfig) GetClientConfig(clientName string) *Client {
@@ -73,6 +101,46 @@ func (c *ClientConfig) GetClientConfig(clientName string) *Client {
 	return client
 }
 
+// GetClientByClientId returns the configured client with the given auth0
+// client id, or nil if there is none.
+func (c *ClientConfig) GetClientByClientId(clientId string) *Client {
+	for _, v := range c.data {
+		if v.ClientId == clientId {
+			return v
+		}
+	}
+	return nil
+}
+
+// GetClients returns the configured clients of a tenant that have a tag,
+// sorted by name. Empty names match every tenant and tag.
+func (c *ClientConfig) GetClients(tenantName string, tag string) []*Client {
+	clients := make([]*Client, 0, len(c.data))
+	for _, client := range c.data {
+		if tenantName != "" && !strings.EqualFold(client.TenantName, tenantName) {
+			continue
+		}
+		if tag != "" && !client.HasTag(tag) {
+			continue
+		}
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ClientName < clients[j].ClientName
+	})
+	return clients
+}
+
+// HasTag reports whether the client is tagged with tag
+func (c *Client) HasTag(tag string) bool {
+	for _, t := range c.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
 // SetAWSProfile sets the profile in the local cache and the store
 func (c *ClientConfig) SetClient(client *Client) {
 	c.v.Set(client.ClientName, client)
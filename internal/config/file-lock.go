@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"path"
+)
+
+// withConfigLock runs fn while holding an exclusive lock on the lock file in
+// the config dir, waiting for other spsauth0 processes, or the agent, to
+// release it first. Files several of them update are loaded, changed and
+// saved under it so none of them loses the changes of another. fn must not
+// take the lock again.
+func withConfigLock(fn func(rootConfigDir string) error) error {
+	rootConfigDir, err := InitConfigDirWithViper()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path.Join(rootConfigDir, ConfigLockFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	return fn(rootConfigDir)
+}
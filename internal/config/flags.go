@@ -1,14 +1,190 @@
 package config
 
+import "time"
+
 const (
 	KeyRootCmdConfigDir     = "configdir"
 	TenantConfigFile        = "tenant-config.yaml"
 	Auth0DeployConfigFile   = "a0deploy-config.json"
 	ClientConfigFile        = "client-config.yaml"
+	TokenCacheFile          = "token-cache.yaml"
+	RefreshTokenFile        = "refresh-tokens.yaml"
+	DiscoveryCacheFile      = "discovery-cache.yaml"
+	AgentSocketFile         = "agent.sock"
+	AgentLogFile            = "agent.log"
+	SecretStoreFile         = "secret-store.yaml"
+	SecretVaultFile         = "secret-vault.json"
+	ConfigLockFile          = ".lock"
 	FlagRootCmdConfigDir    = "config-dir"
 	DescRootCmdConfigDir    = "directory of spsauth0 configuration files."
 	DefaultRootCmdConfigDir = "~/.spsauth0"
 
 	KeyCmdTenantName  = "tenant_name"
 
+	FlagCmdClientTokenNoCache = "no-cache"
+	DescCmdClientTokenNoCache = "always request a new token instead of reusing a cached one."
+	KeyCmdClientTokenNoCache  = "client_token_no_cache"
+
+	FlagCmdClientTokenRefresh = "refresh"
+	DescCmdClientTokenRefresh = "ignore the cached access token and exchange the stored refresh token for a new one."
+	KeyCmdClientTokenRefresh  = "client_token_refresh"
+
+	FlagCmdClientTokenFlow = "flow"
+	DescCmdClientTokenFlow = "how to obtain a user token: browser, device or password. Defaults to the flow configured on the client."
+	KeyCmdClientTokenFlow  = "client_token_flow"
+
+	FlagCmdClientTokenUsername       = "username"
+	DescCmdClientTokenUsername       = "test user to log in as with the password flow."
+	KeyCmdClientTokenUsername        = "client_token_username"
+	FlagCmdClientTokenPassword       = "password"
+	DescCmdClientTokenPassword       = "password of the test user for the password flow."
+	KeyCmdClientTokenPassword        = "client_token_password"
+	FlagCmdClientTokenRedirectURI    = "redirect-uri"
+	DescCmdClientTokenRedirectURI    = "callback URI the browser flow listens on. Defaults to the one configured on the client."
+	KeyCmdClientTokenRedirectURI     = "client_token_redirect_uri"
+	FlagCmdClientTokenRedirectPorts  = "redirect-ports"
+	DescCmdClientTokenRedirectPorts  = "ports to try in order for the browser flow's callback, in place of the redirect URI's port."
+	KeyCmdClientTokenRedirectPorts   = "client_token_redirect_ports"
+	FlagCmdClientTokenNoBrowser      = "no-browser"
+	DescCmdClientTokenNoBrowser      = "print the login URL instead of opening a browser and accept the redirect URL pasted back."
+	KeyCmdClientTokenNoBrowser       = "client_token_no_browser"
+	FlagCmdClientTokenAudience       = "audience"
+	DescCmdClientTokenAudience       = "audience to request the token for instead of the client's or tenant's default."
+	KeyCmdClientTokenAudience        = "client_token_audience"
+	FlagCmdClientTokenScope          = "scope"
+	DescCmdClientTokenScope          = "space separated API permissions to request instead of picking them from the audience's API."
+	KeyCmdClientTokenScope           = "client_token_scope"
+	FlagCmdClientTokenOrganization   = "organization"
+	DescCmdClientTokenOrganization   = "organization to log in to, overrides the client's default."
+	KeyCmdClientTokenOrganization    = "client_token_organization"
+	FlagCmdClientTokenConnection     = "connection"
+	DescCmdClientTokenConnection     = "connection to log in with, overrides the client's default."
+	KeyCmdClientTokenConnection      = "client_token_connection"
+	FlagCmdClientTokenPrompt         = "prompt"
+	DescCmdClientTokenPrompt         = "prompt parameter of the login, e.g. login or consent."
+	KeyCmdClientTokenPrompt          = "client_token_prompt"
+	FlagCmdClientTokenScreenHint     = "screen-hint"
+	DescCmdClientTokenScreenHint     = "screen_hint parameter of the login, e.g. signup."
+	KeyCmdClientTokenScreenHint      = "client_token_screen_hint"
+	FlagCmdClientTokenLoginHint      = "login-hint"
+	DescCmdClientTokenLoginHint      = "login_hint parameter of the login, e.g. the user's email."
+	KeyCmdClientTokenLoginHint       = "client_token_login_hint"
+	FlagCmdClientTokenMaxAge         = "max-age"
+	DescCmdClientTokenMaxAge         = "max_age parameter of the login, in seconds."
+	KeyCmdClientTokenMaxAge          = "client_token_max_age"
+	FlagCmdClientTokenParam          = "param"
+	DescCmdClientTokenParam          = "extra name=value parameter to send to /authorize and /oauth/token, can be repeated."
+	KeyCmdClientTokenParam           = "client_token_param"
+	FlagCmdClientTokenOutput         = "output"
+	DescCmdClientTokenOutput         = "how to print the token: raw, json, env or header."
+	KeyCmdClientTokenOutput          = "client_token_output"
+	FlagCmdClientTokenCopy           = "copy"
+	DescCmdClientTokenCopy           = "copy the output to the system clipboard instead of printing it, when a clipboard is available."
+	KeyCmdClientTokenCopy            = "client_token_copy"
+	FlagCmdClientTokenAll            = "all"
+	DescCmdClientTokenAll            = "fetch a client credentials token for every machine to machine client and report how each went."
+	KeyCmdClientTokenAll             = "client_token_all"
+	FlagCmdClientTokenTenant         = "tenant"
+	DescCmdClientTokenTenant         = "with --all, only fetch tokens for clients of this tenant."
+	KeyCmdClientTokenTenant          = "client_token_tenant"
+	FlagCmdClientTokenTag            = "tag"
+	DescCmdClientTokenTag            = "with --all, only fetch tokens for clients with this tag."
+	KeyCmdClientTokenTag             = "client_token_tag"
+	FlagCmdClientTokenWorkers        = "workers"
+	DescCmdClientTokenWorkers        = "with --all, how many tokens to fetch at once."
+	KeyCmdClientTokenWorkers         = "client_token_workers"
+	DefaultClientTokenWorkers        = 8
+	FlagCmdClientTokenSnippet        = "snippet"
+	DescCmdClientTokenSnippet        = "print the token request in curl, httpie, python, node, go or powershell instead of running it."
+	KeyCmdClientTokenSnippet         = "client_token_snippet"
+	FlagCmdClientTokenIncludeSecrets = "include-secrets"
+	DescCmdClientTokenIncludeSecrets = "put the client secret and password in the snippet instead of placeholders."
+	KeyCmdClientTokenIncludeSecrets  = "client_token_include_secrets"
+
+	KeyCmdClientUserinfoNoCache   = "client_userinfo_no_cache"
+	KeyCmdClientUserinfoFlow      = "client_userinfo_flow"
+	KeyCmdClientUserinfoNoBrowser = "client_userinfo_no_browser"
+	EnvTestUsername               = "SPSAUTH0_USERNAME"
+	EnvTestPassword               = "SPSAUTH0_PASSWORD"
+
+	FlagCmdTokenClient       = "client"
+	DescCmdTokenClient       = "use the last token cached for this client instead of an argument or stdin."
+	KeyCmdTokenDecodeClient  = "token_decode_client"
+	KeyCmdTokenVerifyClient  = "token_verify_client"
+	FlagCmdTokenVerifyTenant = "tenant"
+	DescCmdTokenVerifyTenant = "tenant the token should have been issued by, defaults to the tenant matching the iss claim."
+	KeyCmdTokenVerifyTenant  = "token_verify_tenant"
+	FlagCmdTokenVerifyJWKS   = "jwks"
+	DescCmdTokenVerifyJWKS   = "path to a local JWKS file to verify against instead of the tenant's jwks.json."
+	KeyCmdTokenVerifyJWKS    = "token_verify_jwks"
+	FlagCmdTokenVerifySkew   = "clock-skew"
+	DescCmdTokenVerifySkew   = "clock skew allowed when checking exp and nbf."
+	KeyCmdTokenVerifySkew    = "token_verify_clock_skew"
+	DefaultTokenVerifySkew   = 30 * time.Second
+
+	FlagCmdTokenRevokeTenant = "tenant"
+	DescCmdTokenRevokeTenant = "revoke the tokens of every client of this tenant."
+	KeyCmdTokenRevokeTenant  = "token_revoke_tenant"
+	FlagCmdTokenRevokeClient = "client"
+	DescCmdTokenRevokeClient = "revoke the tokens of this client."
+	KeyCmdTokenRevokeClient  = "token_revoke_client"
+
+	FlagCmdTokenRevokeUsername = "username"
+	DescCmdTokenRevokeUsername = "only revoke the tokens of this password flow test user."
+	KeyCmdTokenRevokeUsername  = "token_revoke_username"
+
+	FlagCmdExecClient        = "client"
+	DescCmdExecClient        = "client to get the token for, prompted for when not given."
+	KeyCmdExecClient         = "exec_client"
+	KeyCmdExecAudience       = "exec_audience"
+	KeyCmdExecScope          = "exec_scope"
+	KeyCmdExecNoCache        = "exec_no_cache"
+	FlagCmdExecIncludeSecret = "include-secret"
+	DescCmdExecIncludeSecret = "also set the client secret in the command's environment."
+	KeyCmdExecIncludeSecret  = "exec_include_secret"
+
+	FlagCmdProxyClient   = "client"
+	DescCmdProxyClient   = "client to get tokens for, prompted for when not given."
+	KeyCmdProxyClient    = "proxy_client"
+	KeyCmdProxyAudience  = "proxy_audience"
+	KeyCmdProxyScope     = "proxy_scope"
+	FlagCmdProxyListen   = "listen"
+	DescCmdProxyListen   = "address the proxy listens on."
+	KeyCmdProxyListen    = "proxy_listen"
+	DefaultProxyListen   = "localhost:8080"
+	FlagCmdProxyUpstream = "upstream"
+	DescCmdProxyUpstream = "URL of the API requests are forwarded to."
+	KeyCmdProxyUpstream  = "proxy_upstream"
+
+	FlagCmdSecretInitBackend   = "backend"
+	DescCmdSecretInitBackend   = "where to keep secrets: vault or keyring."
+	KeyCmdSecretInitBackend    = "secret_init_backend"
+	FlagCmdSecretUnlockTimeout = "timeout"
+	DescCmdSecretUnlockTimeout = "how long the agent keeps the vault unlocked for other commands, 0 until it stops."
+	KeyCmdSecretUnlockTimeout  = "secret_unlock_timeout"
+	DefaultSecretUnlockTimeout = 12 * time.Hour
+	EnvVaultPassphrase         = "SPSAUTH0_VAULT_PASSPHRASE"
+
+	FlagCmdCredentialClient  = "client"
+	DescCmdCredentialClient  = "client to get the token for."
+	KeyCmdCredentialClient   = "credential_client"
+	KeyCmdCredentialAudience = "credential_audience"
+	KeyCmdCredentialScope    = "credential_scope"
+	EnvCredentialClient      = "SPSAUTH0_CLIENT"
+
+	FlagCmdCredentialDockerRegistry = "registry"
+	DescCmdCredentialDockerRegistry = "registry the token is handed to, can be repeated. Other registries are told there are no credentials."
+	KeyCmdCredentialDockerRegistry  = "credential_docker_registry"
+
+	FlagCmdClientTokenPurgeTenant = "tenant"
+	DescCmdClientTokenPurgeTenant = "only purge cached tokens for this tenant."
+	KeyCmdClientTokenPurgeTenant  = "client_token_purge_tenant"
+
+	FlagCmdClientTokenPurgeClient = "client"
+	DescCmdClientTokenPurgeClient = "only purge cached tokens for this client."
+	KeyCmdClientTokenPurgeClient  = "client_token_purge_client"
+
+	FlagCmdClientTokenPurgeUsername = "username"
+	DescCmdClientTokenPurgeUsername = "only purge cached tokens of this password flow test user."
+	KeyCmdClientTokenPurgeUsername  = "client_token_purge_username"
 )
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// DiscoveryCacheTTL is how long a tenant's OIDC configuration is reused before
+// it is fetched again
+const DiscoveryCacheTTL = 24 * time.Hour
+
+// OIDCConfiguration holds the parts of a tenant's
+// /.well-known/openid-configuration spsauth0 uses
+type OIDCConfiguration struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint   string   `json:"device_authorization_endpoint"`
+	RevocationEndpoint            string   `json:"revocation_endpoint"`
+	UserinfoEndpoint              string   `json:"userinfo_endpoint"`
+	JwksURI                       string   `json:"jwks_uri"`
+	GrantTypesSupported           []string `json:"grant_types_supported"`
+	ResponseTypesSupported        []string `json:"response_types_supported"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+	FetchedAt                     int64    `json:"-"`
+}
+
+// DiscoveryCache represents the OIDC configurations spsauth0 stores per tenant
+type DiscoveryCache struct {
+	data map[string]*OIDCConfiguration
+	v    *viper.Viper
+}
+
+// LoadDiscoveryCacheWithViper sets the path to the discovery cache using the
+// viper config (i.e. --configdir) and then ensures the file exists and loads it.
+func LoadDiscoveryCacheWithViper() (*DiscoveryCache, error) {
+	rootConfigDir, err := InitConfigDirWithViper()
+	if err != nil {
+		return nil, err
+	}
+
+	cacheFile := path.Join(rootConfigDir, DiscoveryCacheFile)
+
+	return LoadDiscoveryCache(cacheFile)
+}
+
+// UpdateDiscoveryCacheWithViper loads the discovery cache while holding the
+// config dir lock, lets update change it and saves it.
+func UpdateDiscoveryCacheWithViper(update func(*DiscoveryCache)) error {
+	return withConfigLock(func(rootConfigDir string) error {
+		discoveryCache, err := LoadDiscoveryCache(path.Join(rootConfigDir, DiscoveryCacheFile))
+		if err != nil {
+			return err
+		}
+		update(discoveryCache)
+		return discoveryCache.SaveDiscoveryCache()
+	})
+}
+
+// LoadDiscoveryCache ensures the discovery cache file exists and then loads it.
+func LoadDiscoveryCache(cacheFile string) (*DiscoveryCache, error) {
+	v, err := ensureTenantConfig(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	c := DiscoveryCache{
+		data: make(map[string]*OIDCConfiguration),
+		v:    v,
+	}
+	err = v.Unmarshal(&c.data)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetConfiguration returns the OIDC configuration cached for the tenant, or nil
+// if there is none or it is older than DiscoveryCacheTTL.
+func (c *DiscoveryCache) GetConfiguration(tenantName string) *OIDCConfiguration {
+	oidc, ok := c.data[strings.ToLower(tenantName)]
+	if !ok || time.Since(time.Unix(oidc.FetchedAt, 0)) > DiscoveryCacheTTL {
+		return nil
+	}
+	return oidc
+}
+
+// SetConfiguration sets the tenant's OIDC configuration in the local cache and
+// the store
+func (c *DiscoveryCache) SetConfiguration(tenantName string, oidc *OIDCConfiguration) {
+	c.data[strings.ToLower(tenantName)] = oidc
+	c.v.Set(strings.ToLower(tenantName), oidc)
+}
+
+// DeleteConfiguration drops the tenant's OIDC configuration, e.g. once its
+// domain changed, so the next command discovers it again
+func (c *DiscoveryCache) DeleteConfiguration(tenantName string) {
+	delete(c.data, strings.ToLower(tenantName))
+}
+
+// SaveDiscoveryCache writes the cache back to disk
+func (c *DiscoveryCache) SaveDiscoveryCache() error {
+	entries := make(map[string]interface{}, len(c.data))
+	for k, oidc := range c.data {
+		entries[k] = oidc
+	}
+
+	v, err := rewritePrivateConfig(c.v, entries)
+	if err != nil {
+		return err
+	}
+	c.v = v
+	return nil
+}
+
+// SupportsGrantType reports whether the tenant advertises the grant type. A
+// tenant that does not list its grant types is assumed to support it.
+func (o *OIDCConfiguration) SupportsGrantType(grantType string) bool {
+	return len(o.GrantTypesSupported) == 0 || containsString(o.GrantTypesSupported, grantType)
+}
+
+// SupportsCodeChallengeMethod reports whether the tenant advertises the PKCE
+// method. A tenant that does not list its methods is assumed to support it.
+func (o *OIDCConfiguration) SupportsCodeChallengeMethod(method string) bool {
+	return len(o.CodeChallengeMethodsSupported) == 0 || containsString(o.CodeChallengeMethodsSupported, method)
+}
+
+// SupportsResponseType reports whether the tenant advertises the response type.
+// A tenant that does not list its response types is assumed to support it.
+func (o *OIDCConfiguration) SupportsResponseType(responseType string) bool {
+	return len(o.ResponseTypesSupported) == 0 || containsString(o.ResponseTypesSupported, responseType)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// StoredRefreshToken is a refresh token auth0 issued to a client of a tenant,
+// for the test user Username with the password flow. With a secret backend
+// configured RefreshToken refers to the token kept there, see
+// RefreshTokenSecretKey.
+type StoredRefreshToken struct {
+	TenantName   string
+	ClientName   string
+	Username     string
+	Audience     string
+	Scope        string
+	Params       string
+	RefreshToken string
+	IssuedAt     int64
+}
+
+// RefreshTokenStore represents the refresh tokens spsauth0 keeps per client
+// and tenant
+type RefreshTokenStore struct {
+	data map[string]*StoredRefreshToken
+	v    *viper.Viper
+}
+
+// LoadRefreshTokenStoreWithViper sets the path to the refresh token store using
+// the viper config (i.e. --configdir) and then ensures the file exists and
+// loads it.
+func LoadRefreshTokenStoreWithViper() (*RefreshTokenStore, error) {
+	rootConfigDir, err := InitConfigDirWithViper()
+	if err != nil {
+		return nil, err
+	}
+
+	storeFile := path.Join(rootConfigDir, RefreshTokenFile)
+
+	return LoadRefreshTokenStore(storeFile)
+}
+
+// LoadRefreshTokenStore ensures the refresh token file exists and then loads it.
+func LoadRefreshTokenStore(storeFile string) (*RefreshTokenStore, error) {
+	v, err := ensurePrivateConfig(storeFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s := RefreshTokenStore{
+		data: make(map[string]*StoredRefreshToken),
+		v:    v,
+	}
+	err = v.Unmarshal(&s.data)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateRefreshTokenStoreWithViper loads the refresh token store while holding
+// the config dir lock, lets update change it and saves it.
+func UpdateRefreshTokenStoreWithViper(update func(*RefreshTokenStore)) error {
+	return withConfigLock(func(rootConfigDir string) error {
+		store, err := LoadRefreshTokenStore(path.Join(rootConfigDir, RefreshTokenFile))
+		if err != nil {
+			return err
+		}
+		update(store)
+		return store.SaveRefreshTokenStore()
+	})
+}
+
+// RefreshTokenKey builds the key a refresh token is stored under. A refresh
+// token only yields access tokens for the audience, scope and login parameters
+// it was issued for, so a client has one for each. The order of the scopes
+// does not matter. username is the test user it was issued to, empty for
+// other flows.
+func RefreshTokenKey(tenantName string, clientName string, audience string, scope string, params string, username string) string {
+	scopes := strings.Fields(scope)
+	sort.Strings(scopes)
+
+	return hashKey(strings.ToLower(tenantName), strings.ToLower(clientName), audience, strings.Join(scopes, " "), params, username)
+}
+
+// GetRefreshToken returns the refresh token stored for the client of the
+// tenant, issued for the audience, scope and login parameters to the test
+// user, or nil if there is none.
+func (s *RefreshTokenStore) GetRefreshToken(tenantName string, clientName string, audience string, scope string, params string, username string) *StoredRefreshToken {
+	token, ok := s.data[RefreshTokenKey(tenantName, clientName, audience, scope, params, username)]
+	if !ok || token.RefreshToken == "" {
+		return nil
+	}
+	return token
+}
+
+// GetRefreshTokens returns every refresh token stored for the given tenant
+// and client names and test user; an empty name matches everything.
+func (s *RefreshTokenStore) GetRefreshTokens(tenantName string, clientName string, username string) []*StoredRefreshToken {
+	tokens := make([]*StoredRefreshToken, 0)
+	for _, token := range s.data {
+		if token.RefreshToken == "" {
+			continue
+		}
+		if tenantName != "" && !strings.EqualFold(token.TenantName, tenantName) {
+			continue
+		}
+		if clientName != "" && !strings.EqualFold(token.ClientName, clientName) {
+			continue
+		}
+		if username != "" && token.Username != username {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// SetRefreshToken stores the token, replacing the refresh token previously
+// stored for the same client, audience, scope, login parameters and test
+// user, which auth0 rotated away.
+func (s *RefreshTokenStore) SetRefreshToken(token *StoredRefreshToken) {
+	s.data[refreshTokenKeyOf(token)] = token
+}
+
+// DeleteRefreshToken removes the token from the store
+func (s *RefreshTokenStore) DeleteRefreshToken(token *StoredRefreshToken) {
+	delete(s.data, refreshTokenKeyOf(token))
+}
+
+func refreshTokenKeyOf(token *StoredRefreshToken) string {
+	return RefreshTokenKey(token.TenantName, token.ClientName, token.Audience, token.Scope, token.Params, token.Username)
+}
+
+// RefreshTokenSecretKey returns the key a refresh token is kept under in the
+// secret backend. Every token gets its own, so a reference tells a rotated
+// token from the one replacing it.
+func RefreshTokenSecretKey(refreshToken string) string {
+	return "refreshtokens/" + hashKey(refreshToken)
+}
+
+// SaveRefreshTokenStore writes the store back to disk
+func (s *RefreshTokenStore) SaveRefreshTokenStore() error {
+	entries := make(map[string]interface{}, len(s.data))
+	for k, token := range s.data {
+		entries[k] = token
+	}
+
+	v, err := rewritePrivateConfig(s.v, entries)
+	if err != nil {
+		return err
+	}
+	s.v = v
+	return nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService is the service secrets are filed under in the OS keyring
+const keyringService = "spsauth0"
+
+// keyringStore is the SecretStore of the OS keyring: the macOS keychain, the
+// Secret Service on Linux or the Windows credential manager
+type keyringStore struct{}
+
+func (keyringStore) Get(key string) (string, error) {
+	secret, err := keyring.Get(keyringService, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("secret %s is not in the keyring", key)
+	}
+	return secret, err
+}
+
+func (keyringStore) Set(key string, value string) error {
+	return keyring.Set(keyringService, key, value)
+}
+
+func (keyringStore) Delete(key string) error {
+	err := keyring.Delete(keyringService, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// CheckKeyring returns an error when there is no OS keyring to store secrets
+// in
+func CheckKeyring() error {
+	const probe = "spsauth0-probe"
+	if err := keyring.Set(keyringService, probe, probe); err != nil {
+		return err
+	}
+	return keyring.Delete(keyringService, probe)
+}
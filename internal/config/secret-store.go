@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Backends the secrets of clients can be kept in. Without one they stay in
+// the config files as they are.
+const (
+	SecretBackendVault   = "vault"
+	SecretBackendKeyring = "keyring"
+)
+
+func GetSupportedSecretBackends() []string {
+	return []string{SecretBackendVault, SecretBackendKeyring}
+}
+
+// SecretStore keeps the secrets the config files refer to. Keys are paths
+// like clients/<client>/clientsecret.
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	Delete(key string) error
+}
+
+// OpenSecretStore returns the store of a backend
+func OpenSecretStore(backend string) (SecretStore, error) {
+	switch backend {
+	case SecretBackendVault:
+		return openVault()
+	case SecretBackendKeyring:
+		return keyringStore{}, nil
+	}
+	return nil, fmt.Errorf("unsupported secret backend %s, use one of %v", backend, GetSupportedSecretBackends())
+}
+
+// IsSecretRef reports whether a config value refers to a secret in a store
+// instead of holding the secret itself
+func IsSecretRef(value string) bool {
+	backend, key := splitSecretRef(value)
+	return backend != "" && key != ""
+}
+
+// splitSecretRef splits a reference like vault:clients/ui/clientsecret
+func splitSecretRef(value string) (string, string) {
+	for _, backend := range GetSupportedSecretBackends() {
+		if strings.HasPrefix(value, backend+":") {
+			return backend, strings.TrimPrefix(value, backend+":")
+		}
+	}
+	return "", ""
+}
+
+// GetSecretBackend returns the backend new secrets are stored in, or "" when
+// secrets are kept in the config files
+func GetSecretBackend() (string, error) {
+	v, err := loadSecretStoreConfig()
+	if err != nil {
+		return "", err
+	}
+	return v.GetString("backend"), nil
+}
+
+// SetSecretBackend makes backend the one new secrets are stored in
+func SetSecretBackend(backend string) error {
+	v, err := loadSecretStoreConfig()
+	if err != nil {
+		return err
+	}
+	v.Set("backend", backend)
+	return v.WriteConfig()
+}
+
+func loadSecretStoreConfig() (*viper.Viper, error) {
+	rootConfigDir, err := InitConfigDirWithViper()
+	if err != nil {
+		return nil, err
+	}
+	return ensureTenantConfig(path.Join(rootConfigDir, SecretStoreFile))
+}
+
+// ResolveClientSecrets returns a copy of the client with the secrets its
+// config refers to read from their store. Clients whose secrets are in the
+// config are returned as they are.
+func ResolveClientSecrets(client *Client) (*Client, error) {
+	resolved := *client
+	for _, field := range []*string{&resolved.ClientSecret, &resolved.Password} {
+		secret, err := ResolveSecret(*field)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the secrets of client %s - %w", client.ClientName, err)
+		}
+		*field = secret
+	}
+	return &resolved, nil
+}
+
+// ResolveSecret returns the secret a config value refers to, or the value
+// itself when it holds the secret
+func ResolveSecret(value string) (string, error) {
+	if !IsSecretRef(value) {
+		return value, nil
+	}
+	backend, key := splitSecretRef(value)
+	store, err := OpenSecretStore(backend)
+	if err != nil {
+		return "", err
+	}
+	return store.Get(key)
+}
+
+// StoreSecret keeps a secret spsauth0 obtained itself, such as a refresh
+// token, under key in the configured backend and returns the reference to
+// save in its place. Without a backend the secret is returned as it is.
+func StoreSecret(key string, secret string) (string, error) {
+	backend, err := GetSecretBackend()
+	if err != nil || backend == "" {
+		return secret, err
+	}
+	store, err := OpenSecretStore(backend)
+	if err != nil {
+		return "", err
+	}
+	if err := store.Set(key, secret); err != nil {
+		return "", err
+	}
+	return backend + ":" + key, nil
+}
+
+// DeleteSecret removes the secret a config value refers to from its store.
+// Values holding the secret itself are left alone.
+func DeleteSecret(value string) error {
+	if !IsSecretRef(value) {
+		return nil
+	}
+	backend, key := splitSecretRef(value)
+	store, err := OpenSecretStore(backend)
+	if err != nil {
+		return err
+	}
+	return store.Delete(key)
+}
+
+// StoreClientSecrets moves the secrets of a client being added into the
+// configured backend and returns the client to save, which refers to them.
+func StoreClientSecrets(client *Client) (*Client, error) {
+	stored, _, err := storeClientSecrets(client, "clients/"+strings.ToLower(client.ClientName))
+	return stored, err
+}
+
+// storeClientSecrets moves the secrets of the client into the configured
+// backend under prefix, returning the client referring to them and how many
+// secrets were moved
+func storeClientSecrets(client *Client, prefix string) (*Client, int, error) {
+	backend, err := GetSecretBackend()
+	if err != nil || backend == "" {
+		return client, 0, err
+	}
+	store, err := OpenSecretStore(backend)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stored := *client
+	moved := 0
+	fields := map[string]*string{
+		"clientsecret": &stored.ClientSecret,
+		"password":     &stored.Password,
+	}
+	for name, field := range fields {
+		if *field == "" || IsSecretRef(*field) {
+			continue
+		}
+		key := prefix + "/" + name
+		if err := store.Set(key, *field); err != nil {
+			return nil, 0, fmt.Errorf("could not store the %s of client %s - %w", name, client.ClientName, err)
+		}
+		*field = backend + ":" + key
+		moved++
+	}
+	return &stored, moved, nil
+}
+
+// StoreSecrets moves the secrets of every client into the configured backend
+// and returns how many were moved. The config has to be saved afterwards.
+func (c *ClientConfig) StoreSecrets() (int, error) {
+	total := 0
+	for name, client := range c.data {
+		stored, moved, err := storeClientSecrets(client, "clients/"+name)
+		if err != nil {
+			return total, err
+		}
+		if moved > 0 {
+			c.data[name] = stored
+			c.SetClient(stored)
+			total += moved
+		}
+	}
+	return total, nil
+}
+
+// StoreSecrets moves the secrets of the tenants' default clients into the
+// configured backend and returns how many were moved. The config has to be
+// saved afterwards.
+func (a *TenantConfig) StoreSecrets() (int, error) {
+	total := 0
+	for name, tenant := range a.data {
+		if tenant.Tenant.DefaultClient == nil {
+			continue
+		}
+		// the default client is a copy which may have gone stale, so its
+		// secrets are kept apart from the client's
+		stored, moved, err := storeClientSecrets(tenant.Tenant.DefaultClient, "tenants/"+name+"/defaultclient")
+		if err != nil {
+			return total, err
+		}
+		if moved > 0 {
+			tenant.Tenant.DefaultClient = stored
+			a.SetTenant(name, tenant)
+			total += moved
+		}
+	}
+	return total, nil
+}
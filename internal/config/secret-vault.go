@@ -0,0 +1,337 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrVaultLocked means the vault's key is not known to this process
+var ErrVaultLocked = errors.New("the secret vault is locked, run 'spsauth0 secret unlock'")
+
+// vaultAAD ties the ciphertext to the vault format
+const vaultAAD = "spsauth0-vault-v1"
+
+// scrypt parameters of new vaults, the ones a vault was created with are
+// stored in it
+const (
+	vaultScryptN = 1 << 15
+	vaultScryptR = 8
+	vaultScryptP = 1
+)
+
+// vaultFile is the vault as written to disk. The secrets are a JSON object
+// of keys to secrets, encrypted with AES-256-GCM under a key derived from the
+// passphrase with scrypt.
+type vaultFile struct {
+	Version    int
+	KDF        string
+	N          int
+	R          int
+	P          int
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// vaultKey is the key of the vault once this process has unlocked it or was
+// handed the key, until vaultKeyExpiresAt unless that is zero. The key is
+// only ever kept in memory.
+var (
+	vaultMu           sync.Mutex
+	vaultKey          []byte
+	vaultKeyExpiresAt time.Time
+)
+
+func setVaultKey(key []byte, until time.Time) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	vaultKey, vaultKeyExpiresAt = key, until
+}
+
+// VaultKey returns the key this process holds for the vault and until when,
+// the zero time meaning for as long as the process runs. It is nil while the
+// vault is locked for the process.
+func VaultKey() ([]byte, time.Time) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	if vaultKey != nil && !vaultKeyExpiresAt.IsZero() && time.Now().After(vaultKeyExpiresAt) {
+		vaultKey, vaultKeyExpiresAt = nil, time.Time{}
+	}
+	return vaultKey, vaultKeyExpiresAt
+}
+
+// fileVault is the SecretStore of the encrypted vault file
+type fileVault struct {
+	path string
+}
+
+func vaultPath(name string) (string, error) {
+	rootConfigDir, err := InitConfigDirWithViper()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(rootConfigDir, name), nil
+}
+
+func openVault() (*fileVault, error) {
+	vaultFilePath, err := vaultPath(SecretVaultFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(vaultFilePath); err != nil {
+		return nil, fmt.Errorf("there is no secret vault, run 'spsauth0 secret init' - %v", err)
+	}
+	return &fileVault{path: vaultFilePath}, nil
+}
+
+// VaultExists reports whether the vault has been created
+func VaultExists() bool {
+	vaultFilePath, err := vaultPath(SecretVaultFile)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(vaultFilePath)
+	return err == nil
+}
+
+// CreateVault creates an empty vault encrypted with the passphrase and keeps
+// it unlocked for this process
+func CreateVault(passphrase string) error {
+	vaultFilePath, err := vaultPath(SecretVaultFile)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(vaultFilePath); err == nil {
+		return fmt.Errorf("the secret vault %s already exists", vaultFilePath)
+	}
+
+	f := &vaultFile{Version: 1, KDF: "scrypt", N: vaultScryptN, R: vaultScryptR, P: vaultScryptP, Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	key, err := f.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	setVaultKey(key, time.Time{})
+	return (&fileVault{path: vaultFilePath}).write(f, map[string]string{})
+}
+
+// UnlockVault checks the passphrase and keeps the vault unlocked for this
+// process. It returns the key so it can be handed to the agent, which keeps
+// the vault unlocked for other commands, see UseVaultKey.
+func UnlockVault(passphrase string) ([]byte, error) {
+	vault, err := openVault()
+	if err != nil {
+		return nil, err
+	}
+	f, err := vault.read()
+	if err != nil {
+		return nil, err
+	}
+	key, err := f.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.decrypt(key); err != nil {
+		return nil, errors.New("the passphrase does not unlock the secret vault")
+	}
+	setVaultKey(key, time.Time{})
+	return key, nil
+}
+
+// UseVaultKey keeps the vault unlocked for this process with the key another
+// process unlocked it with, until the given time or, when it is zero, for as
+// long as this process runs
+func UseVaultKey(key []byte, until time.Time) error {
+	vault, err := openVault()
+	if err != nil {
+		return err
+	}
+	f, err := vault.read()
+	if err != nil {
+		return err
+	}
+	if _, err := f.decrypt(key); err != nil {
+		return errors.New("the key does not unlock the secret vault")
+	}
+	setVaultKey(key, until)
+	return nil
+}
+
+// LockVault forgets the key of the vault, it has to be unlocked again
+func LockVault() {
+	setVaultKey(nil, time.Time{})
+}
+
+// key returns the key of the vault: the one this process holds or the one
+// derived from EnvVaultPassphrase
+func (v *fileVault) key(f *vaultFile) ([]byte, error) {
+	if key, _ := VaultKey(); key != nil {
+		return key, nil
+	}
+	if passphrase := os.Getenv(EnvVaultPassphrase); passphrase != "" {
+		key, err := f.deriveKey(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.decrypt(key); err != nil {
+			return nil, fmt.Errorf("%s does not unlock the secret vault", EnvVaultPassphrase)
+		}
+		setVaultKey(key, time.Time{})
+		return key, nil
+	}
+	return nil, ErrVaultLocked
+}
+
+func (v *fileVault) Get(key string) (string, error) {
+	secrets, _, err := v.open()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s is not in the vault", key)
+	}
+	return secret, nil
+}
+
+// Set and Delete hold the config dir lock, the agent and commands store
+// refresh tokens in the vault at the same time
+func (v *fileVault) Set(key string, value string) error {
+	return withConfigLock(func(string) error {
+		secrets, f, err := v.open()
+		if err != nil {
+			return err
+		}
+		secrets[key] = value
+		return v.write(f, secrets)
+	})
+}
+
+func (v *fileVault) Delete(key string) error {
+	return withConfigLock(func(string) error {
+		secrets, f, err := v.open()
+		if err != nil {
+			return err
+		}
+		delete(secrets, key)
+		return v.write(f, secrets)
+	})
+}
+
+// VaultSecretCount returns how many secrets the vault holds
+func VaultSecretCount() (int, error) {
+	vault, err := openVault()
+	if err != nil {
+		return 0, err
+	}
+	secrets, _, err := vault.open()
+	return len(secrets), err
+}
+
+// open reads and decrypts the vault
+func (v *fileVault) open() (map[string]string, *vaultFile, error) {
+	f, err := v.read()
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := v.key(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	secrets, err := f.decrypt(key)
+	if err != nil {
+		// a key handed over from before the vault was recreated
+		LockVault()
+		return nil, nil, ErrVaultLocked
+	}
+	return secrets, f, nil
+}
+
+func (v *fileVault) read() (*vaultFile, error) {
+	data, err := ioutil.ReadFile(v.path)
+	if err != nil {
+		return nil, err
+	}
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("could not read the secret vault - %v", err)
+	}
+	if f.Version != 1 || f.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported secret vault version %d (%s)", f.Version, f.KDF)
+	}
+	return &f, nil
+}
+
+// write encrypts the secrets with a new nonce and replaces the vault file
+func (v *fileVault) write(f *vaultFile, secrets map[string]string) error {
+	key, err := v.key(f)
+	if err != nil {
+		return err
+	}
+	gcm, err := newVaultCipher(key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Ciphertext = gcm.Seal(nil, f.Nonce, plaintext, []byte(vaultAAD))
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write next to the vault and rename so a failed write never leaves a
+	// truncated vault behind
+	tmp := v.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.path)
+}
+
+func (f *vaultFile) deriveKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), f.Salt, f.N, f.R, f.P, 32)
+}
+
+func (f *vaultFile) decrypt(key []byte) (map[string]string, error) {
+	gcm, err := newVaultCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, f.Nonce, f.Ciphertext, []byte(vaultAAD))
+	if err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func newVaultCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
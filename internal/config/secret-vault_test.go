@@ -0,0 +1,101 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// useTempConfigDir points the config dir at a new temp dir and starts the
+// test with a locked vault
+func useTempConfigDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spsauth0-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv(EnvVaultPassphrase)
+	viper.Set(KeyRootCmdConfigDir, dir)
+	LockVault()
+	t.Cleanup(func() {
+		LockVault()
+		viper.Set(KeyRootCmdConfigDir, "")
+		os.RemoveAll(dir)
+	})
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	useTempConfigDir(t)
+
+	if err := CreateVault("correct horse"); err != nil {
+		t.Fatalf("CreateVault: %v", err)
+	}
+	vault, err := openVault()
+	if err != nil {
+		t.Fatalf("openVault: %v", err)
+	}
+	if err := vault.Set("clients/ui/password", "s3cret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, err := vault.Get("clients/ui/password"); err != nil || got != "s3cret" {
+		t.Fatalf("Get = %q, %v, want s3cret", got, err)
+	}
+
+	LockVault()
+	if _, err := vault.Get("clients/ui/password"); !errors.Is(err, ErrVaultLocked) {
+		t.Fatalf("Get on a locked vault = %v, want ErrVaultLocked", err)
+	}
+	if _, err := UnlockVault("wrong horse"); err == nil {
+		t.Fatal("UnlockVault with the wrong passphrase succeeded")
+	}
+	if _, err := vault.Get("clients/ui/password"); !errors.Is(err, ErrVaultLocked) {
+		t.Fatalf("Get after a wrong passphrase = %v, want ErrVaultLocked", err)
+	}
+
+	if _, err := UnlockVault("correct horse"); err != nil {
+		t.Fatalf("UnlockVault: %v", err)
+	}
+	if got, err := vault.Get("clients/ui/password"); err != nil || got != "s3cret" {
+		t.Fatalf("Get after unlocking = %q, %v, want s3cret", got, err)
+	}
+}
+
+func TestVaultDetectsTampering(t *testing.T) {
+	useTempConfigDir(t)
+
+	if err := CreateVault("correct horse"); err != nil {
+		t.Fatalf("CreateVault: %v", err)
+	}
+	vault, err := openVault()
+	if err != nil {
+		t.Fatalf("openVault: %v", err)
+	}
+	if err := vault.Set("clients/ui/password", "s3cret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	f, err := vault.read()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	f.Ciphertext[0] ^= 0xff
+	// write would encrypt the secrets again, so the tampered file is
+	// written the way an attacker would
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(vault.path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vault.Get("clients/ui/password"); !errors.Is(err, ErrVaultLocked) {
+		t.Fatalf("Get of a tampered vault = %v, want ErrVaultLocked", err)
+	}
+	if _, err := UnlockVault("correct horse"); err == nil {
+		t.Fatal("UnlockVault of a tampered vault succeeded")
+	}
+}
@@ -1,10 +1,10 @@
 package config
 
 import (
-	"fmt"
 	"github.com/spf13/viper"
 	"os"
 	"path"
+	"strings"
 )
 
 
@@ -16,18 +16,50 @@ type Auth0Config struct {
 
 // Rename this vales to not have the type client in them.
 type Client struct {
-	ClientId string
+	ClientId     string
 	ClientSecret string
 	ClientName   string
 	ClientType   string
-	TenantName	string
-	Token 		string
-	Audience string
+	TenantName   string
+	Token        string
+	Audience     string
+	Flow         string
+	Realm        string
+	Username     string
+	Password     string
+
+	// PrivateKeyPath and PrivateKeyKid are set instead of ClientSecret for
+	// clients authenticating with Private Key JWT
+	PrivateKeyPath string
+	PrivateKeyKid  string
+
+	// RedirectURI is the callback the browser flow listens on, RedirectPorts
+	// are tried in order in place of its port when set
+	RedirectURI   string
+	RedirectPorts []int
+
+	// Organization, Connection, Prompt, ScreenHint, LoginHint and MaxAge are
+	// the auth0 login parameters sent by default. ExtraParams are any other
+	// parameters to send, as name=value.
+	Organization string
+	Connection   string
+	Prompt       string
+	ScreenHint   string
+	LoginHint    string
+	MaxAge       string
+	ExtraParams  []string
+
+	// Tags group clients, e.g. by team or service, for commands working on
+	// several clients at once
+	Tags []string
 }
 
 type API struct {
 	Name     string
 	Audience string
+	// Permissions are the scopes defined on the API that tokens for it can
+	// be requested with
+	Permissions []string
 }
 
 type TenantProfile struct {
@@ -35,6 +67,9 @@ type TenantProfile struct {
 	Domain string
 	APIs   []API
 	DefaultClient *Client
+	// DefaultAudience is the audience of the API tokens are requested for
+	// when neither the command line nor the client name one
+	DefaultAudience string
 }
 
 // Remove this struct. No reason to have this be a wrapper around TenantProfile
@@ -127,6 +162,24 @@ func (t *TenantConfig) GetTenantConfig(tenantName string) *Tenant {
 	return tenant
 }
 
+// GetTenantByDomain returns the tenant configured with the given domain, or
+// nil if there is none. The domain may be given as an issuer URL.
+func (t *TenantConfig) GetTenantByDomain(domain string) *Tenant {
+	for _, v := range t.data {
+		if strings.EqualFold(trimDomain(v.Tenant.Domain), trimDomain(domain)) {
+			return v
+		}
+	}
+	return nil
+}
+
+// trimDomain strips the scheme and trailing slash from a domain or issuer URL
+func trimDomain(domain string) string {
+	domain = strings.TrimPrefix(domain, "https://")
+	domain = strings.TrimPrefix(domain, "http://")
+	return strings.TrimSuffix(domain, "/")
+}
+
 // SetAWSProfile sets the profile in the local cache and the store
 func (a *TenantConfig) SetTenant(profileName string, profile *Tenant) {
 	a.data[profileName] = profile
@@ -165,8 +218,18 @@ func(a *TenantConfig) GetTenantListNames() []string {
 	return list
 }
 
+// GetAPIByAudience returns the API of the tenant with the given audience, or
+// nil if the tenant has none.
+func (t *Tenant) GetAPIByAudience(audience string) *API {
+	for i, api := range t.Tenant.APIs {
+		if api.Audience == audience {
+			return &t.Tenant.APIs[i]
+		}
+	}
+	return nil
+}
+
 func(a *TenantConfig) GetTenantAPINames(tenantAPIs []API) []string {
-	fmt.Print(tenantAPIs)
 	list := make([]string, 0, len(tenantAPIs))
 	for _, v := range tenantAPIs {
 		list = append(list, v.Name)
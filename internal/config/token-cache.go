@@ -0,0 +1,255 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// TokenExpiryLeeway is how long before its expiry a cached token stops being
+// handed out, so callers never receive a token that dies mid-request.
+const TokenExpiryLeeway = 60 * time.Second
+
+// CachedToken is an access token obtained from auth0 along with what it was
+// requested for. Scope is the scope that was requested, GrantedScope the one
+// auth0 returned. Params are the encoded login parameters and Username the
+// test user of password flow tokens.
+type CachedToken struct {
+	TenantName   string
+	ClientName   string
+	Username     string
+	Audience     string
+	Scope        string
+	Params       string
+	AccessToken  string
+	IDToken      string
+	GrantedScope string
+	TokenType    string
+	ExpiresIn    int
+	ExpiresAt    int64
+}
+
+// TokenCache represents the access tokens spsauth0 stores between runs
+type TokenCache struct {
+	data map[string]*CachedToken
+	v    *viper.Viper
+}
+
+// LoadTokenCacheWithViper sets the path to the token cache using the viper
+// config (i.e. --configdir) and then ensures the cache file exists and loads it.
+func LoadTokenCacheWithViper() (*TokenCache, error) {
+	rootConfigDir, err := InitConfigDirWithViper()
+	if err != nil {
+		return nil, err
+	}
+
+	cacheFile := path.Join(rootConfigDir, TokenCacheFile)
+
+	return LoadTokenCache(cacheFile)
+}
+
+// LoadTokenCache ensures the token cache file exists and then loads it.
+func LoadTokenCache(cacheFile string) (*TokenCache, error) {
+	v, err := ensurePrivateConfig(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	c := TokenCache{
+		data: make(map[string]*CachedToken),
+		v:    v,
+	}
+	err = v.Unmarshal(&c.data)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UpdateTokenCacheWithViper loads the token cache while holding the config dir
+// lock, lets update change it and saves it.
+func UpdateTokenCacheWithViper(update func(*TokenCache)) error {
+	return withConfigLock(func(rootConfigDir string) error {
+		tokenCache, err := LoadTokenCache(path.Join(rootConfigDir, TokenCacheFile))
+		if err != nil {
+			return err
+		}
+		update(tokenCache)
+		return tokenCache.SaveTokenCache()
+	})
+}
+
+// ensurePrivateConfig is ensureTenantConfig for files holding credentials,
+// which are kept readable by the owner alone.
+func ensurePrivateConfig(cfgFile string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(cfgFile)
+	v.SetConfigPermissions(0600)
+	if err := v.ReadInConfig(); err != nil {
+		if os.IsNotExist(err) {
+			// another process may create it in the meantime, never truncate
+			// what it wrote
+			writeErr := v.SafeWriteConfigAs(cfgFile)
+			if _, exists := writeErr.(viper.ConfigFileAlreadyExistsError); exists {
+				return ensurePrivateConfig(cfgFile)
+			}
+			if writeErr != nil {
+				return nil, writeErr
+			}
+		} else {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// hashKey joins the parts of a key and hashes them. Names and audiences contain
+// the '.' viper uses as a key delimiter, so they cannot be used as keys as is.
+func hashKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenCacheKey builds the key a token is stored under. The order of the
+// scopes does not matter. params are the encoded login parameters, such as the
+// organization, the token was requested with, and username the test user it
+// was issued to, empty for other flows.
+func TokenCacheKey(tenantName string, clientName string, audience string, scope string, params string, username string) string {
+	scopes := strings.Fields(scope)
+	sort.Strings(scopes)
+
+	return hashKey(strings.ToLower(tenantName), strings.ToLower(clientName), audience, strings.Join(scopes, " "), params, username)
+}
+
+// Expiry returns the time at which the cached token expires
+func (t *CachedToken) Expiry() time.Time {
+	return time.Unix(t.ExpiresAt, 0)
+}
+
+// IsValid reports whether the token can still be handed out, i.e. it is not
+// within TokenExpiryLeeway of expiring.
+func (t *CachedToken) IsValid() bool {
+	return t.AccessToken != "" && time.Now().Add(TokenExpiryLeeway).Before(t.Expiry())
+}
+
+// GetToken returns the cached token stored under key, or nil if there is no
+// token or it is close to expiry.
+func (t *TokenCache) GetToken(key string) *CachedToken {
+	token, ok := t.data[key]
+	if !ok || !token.IsValid() {
+		return nil
+	}
+	return token
+}
+
+// GetLatestToken returns the most recently issued token cached for the client,
+// whether it is still valid or not, or nil if there is none.
+func (t *TokenCache) GetLatestToken(clientName string) *CachedToken {
+	var latest *CachedToken
+	for _, v := range t.data {
+		if !strings.EqualFold(v.ClientName, clientName) {
+			continue
+		}
+		if latest == nil || v.ExpiresAt-int64(v.ExpiresIn) > latest.ExpiresAt-int64(latest.ExpiresIn) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// GetTokens returns the valid tokens cached for the given tenant and client
+// names and test user; an empty name matches everything.
+func (t *TokenCache) GetTokens(tenantName string, clientName string, username string) []*CachedToken {
+	tokens := make([]*CachedToken, 0)
+	for _, token := range t.data {
+		if !token.IsValid() {
+			continue
+		}
+		if tenantName != "" && !strings.EqualFold(token.TenantName, tenantName) {
+			continue
+		}
+		if clientName != "" && !strings.EqualFold(token.ClientName, clientName) {
+			continue
+		}
+		if username != "" && token.Username != username {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// SetToken stores the token under key in the local cache
+func (t *TokenCache) SetToken(key string, token *CachedToken) {
+	t.data[key] = token
+}
+
+// Purge removes every token matching the given tenant and client names and
+// test user; an empty name matches everything. It returns the number of tokens
+// removed.
+func (t *TokenCache) Purge(tenantName string, clientName string, username string) int {
+	purged := 0
+	for k, v := range t.data {
+		if tenantName != "" && !strings.EqualFold(v.TenantName, tenantName) {
+			continue
+		}
+		if clientName != "" && !strings.EqualFold(v.ClientName, clientName) {
+			continue
+		}
+		if username != "" && v.Username != username {
+			continue
+		}
+		delete(t.data, k)
+		purged++
+	}
+	return purged
+}
+
+// SaveTokenCache writes the cache back to disk, dropping expired tokens.
+func (t *TokenCache) SaveTokenCache() error {
+	for k, token := range t.data {
+		if time.Now().After(token.Expiry()) {
+			delete(t.data, k)
+		}
+	}
+
+	entries := make(map[string]interface{}, len(t.data))
+	for k, token := range t.data {
+		entries[k] = token
+	}
+
+	v, err := rewritePrivateConfig(t.v, entries)
+	if err != nil {
+		return err
+	}
+	t.v = v
+	return nil
+}
+
+// rewritePrivateConfig replaces the file backing v with entries. viper cannot
+// unset keys, so removing entries means writing a new config from scratch. It
+// is written next to the file and renamed over it, so readers never see it
+// half written.
+func rewritePrivateConfig(v *viper.Viper, entries map[string]interface{}) (*viper.Viper, error) {
+	cfgFile := v.ConfigFileUsed()
+	nv := viper.New()
+	nv.SetConfigFile(cfgFile)
+	nv.SetConfigPermissions(0600)
+	for k, entry := range entries {
+		nv.Set(k, entry)
+	}
+
+	// viper picks the format from the extension, so the temporary file keeps it
+	ext := path.Ext(cfgFile)
+	tmp := strings.TrimSuffix(cfgFile, ext) + ".tmp" + ext
+	if err := nv.WriteConfigAs(tmp); err != nil {
+		return nil, err
+	}
+	return nv, os.Rename(tmp, cfgFile)
+}
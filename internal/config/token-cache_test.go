@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestTokenCacheKey(t *testing.T) {
+	key := TokenCacheKey("Mock", "UI", "api://mock/", "read:x read:y", "organization=org_1", "alice")
+
+	same := []struct {
+		name string
+		key  string
+	}{
+		{"scope order", TokenCacheKey("Mock", "UI", "api://mock/", "read:y read:x", "organization=org_1", "alice")},
+		{"tenant and client case", TokenCacheKey("mock", "ui", "api://mock/", "read:x read:y", "organization=org_1", "alice")},
+	}
+	for _, c := range same {
+		if c.key != key {
+			t.Errorf("%s changes the key", c.name)
+		}
+	}
+
+	different := []struct {
+		name string
+		key  string
+	}{
+		{"test user", TokenCacheKey("Mock", "UI", "api://mock/", "read:x read:y", "organization=org_1", "bob")},
+		{"no test user", TokenCacheKey("Mock", "UI", "api://mock/", "read:x read:y", "organization=org_1", "")},
+		{"scope", TokenCacheKey("Mock", "UI", "api://mock/", "read:x", "organization=org_1", "alice")},
+		{"audience", TokenCacheKey("Mock", "UI", "api://other/", "read:x read:y", "organization=org_1", "alice")},
+		{"login parameters", TokenCacheKey("Mock", "UI", "api://mock/", "read:x read:y", "organization=org_2", "alice")},
+		{"client", TokenCacheKey("Mock", "M2M", "api://mock/", "read:x read:y", "organization=org_1", "alice")},
+	}
+	for _, c := range different {
+		if c.key == key {
+			t.Errorf("a different %s gives the same key", c.name)
+		}
+	}
+}
+
+func TestRefreshTokenKeyPerUserAndScope(t *testing.T) {
+	alice := RefreshTokenKey("mock", "ui", "api://mock/", "read:x", "", "alice")
+	if bob := RefreshTokenKey("mock", "ui", "api://mock/", "read:x", "", "bob"); bob == alice {
+		t.Error("refresh tokens of different test users share a key")
+	}
+	if other := RefreshTokenKey("mock", "ui", "api://mock/", "read:y", "", "alice"); other == alice {
+		t.Error("refresh tokens of different scopes share a key")
+	}
+}
@@ -36,18 +36,11 @@ func main() {
 // update client list to display clients for specific tenant - done
 	// optional --all flag to show all clients
 
-// client token --various flags to generate auth token requests in curl/python/etc
-	//	https://learning.postman.com/docs/sending-requests/generate-code-snippets/
-	//	https://stackoverflow.com/questions/33068128/whats-the-optimal-way-to-execute-a-nodejs-script-from-golang-that-returns-a-st
-
 // User tokens
 	// Need to preload gotjwt test and prod applications for this to work - maybe
 
 // Remove Token from client
 
-// Client add/update have client default audience = use default audience provided by the given tenant
-	// make this editable so you could make a client audience for the management api instead
-
 // Tenant update allow update APIs - how to handle this. Should API be there own cmd? probably not
 // If you set a default client on a tenant you shoudl verifity that the client is set up for your tenant - it already might be
 
@@ -59,5 +52,3 @@ func main() {
 	// refresh dump daily or force get new dump with a command
 
 // add client update
-
-// add state query parameter to wsa, native and spa auth flow
\ No newline at end of file